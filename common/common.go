@@ -1,18 +1,25 @@
 package common
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +36,16 @@ const (
 	// GctExt is the extension for GCT Tengo script files
 	GctExt         = ".gct"
 	defaultTimeout = time.Second * 15
+	// bodySnippetLen limits the body included in response errors
+	bodySnippetLen     = 128
+	redactedQueryValue = "REDACTED"
+
+	// DefaultBackoffBase is the first delay of a Backoff without a base set
+	DefaultBackoffBase = time.Millisecond * 100
+	// DefaultBackoffFactor is the multiplier of a Backoff without one set
+	DefaultBackoffFactor = 2
+	// DefaultBackoffMax caps the delay of a Backoff without a max set
+	DefaultBackoffMax = time.Second * 30
 )
 
 // Vars for common.go operations
@@ -56,6 +73,44 @@ var (
 	errCannotSetInvalidTimeout = errors.New("cannot set new HTTP client with timeout that is equal or less than 0")
 	errUserAgentInvalid        = errors.New("cannot set invalid user agent")
 	errHTTPClientInvalid       = errors.New("custom http client cannot be nil")
+	errEmptyCryptoSymbol       = errors.New("crypto currency symbol is empty")
+	errInvalidCurrencyPair     = errors.New("invalid currency pair")
+	errAmbiguousCurrencyPair   = errors.New("ambiguous currency pair")
+	errInvalidMaxAttempts      = errors.New("max attempts must be above zero")
+	errInvalidClockJumpParams  = errors.New("clock jump interval and threshold must be above zero")
+	errExchangeNameUnset       = errors.New("exchange name unset")
+	errInvalidProxyURL         = errors.New("invalid proxy URL")
+	// ErrUnexpectedContentType is returned when a response does not have the
+	// content type a request expected, such as a captive portal or
+	// maintenance page served in place of JSON
+	ErrUnexpectedContentType = errors.New("unexpected response content type")
+	// ErrUnexpectedHTTPStatus is returned with the response contents when a
+	// request requiring a success status does not receive a 2xx status
+	ErrUnexpectedHTTPStatus = errors.New("unexpected HTTP status")
+	// ErrRetriesExhausted is returned when every retry attempt failed
+	ErrRetriesExhausted = errors.New("retries exhausted")
+
+	exchangeClientsMtx sync.RWMutex
+	// exchangeClients are HTTP clients routed through an exchange specific
+	// proxy, keyed by lower case exchange name
+	exchangeClients = make(map[string]*http.Client)
+
+	addressValidatorsMtx sync.RWMutex
+	// addressValidators are keyed by lower case currency symbol, the built-in
+	// validators can be replaced by RegisterCryptoAddressValidator
+	addressValidators = map[string]CryptoAddressValidator{
+		"btc": regexpAddressValidator("^(bc1|[13])[a-zA-HJ-NP-Z0-9]{25,90}$"),
+		"ltc": regexpAddressValidator("^[L3M][a-km-zA-HJ-NP-Z1-9]{25,34}$"),
+		"eth": regexpAddressValidator("^0x[a-km-z0-9]{40}$"),
+	}
+
+	// knownQuoteCurrencies are used to split pairs which have no delimiter,
+	// matched against the upper cased end of the pair string
+	knownQuoteCurrencies = []string{
+		"USDT", "USDC", "BUSD", "TUSD", "USDP", "DAI",
+		"USD", "EUR", "GBP", "JPY", "AUD", "CAD", "CHF", "KRW", "TRY",
+		"BTC", "XBT", "ETH", "BNB", "XRP", "TRX",
+	}
 )
 
 // SetHTTPClientWithTimeout sets a new *http.Client with different timeout
@@ -96,12 +151,16 @@ func SetHTTPClient(client *http.Client) error {
 // NewHTTPClientWithTimeout initialises a new HTTP client and its underlying
 // transport IdleConnTimeout with the specified timeout duration
 func NewHTTPClientWithTimeout(t time.Duration) *http.Client {
+	return newHTTPClient(t, http.ProxyFromEnvironment)
+}
+
+func newHTTPClient(t time.Duration, proxy func(*http.Request) (*url.URL, error)) *http.Client {
 	tr := &http.Transport{
 		// Added IdleConnTimeout to reduce the time of idle connections which
 		// could potentially slow macOS reconnection when there is a sudden
 		// network disconnection/issue
 		IdleConnTimeout: t,
-		Proxy:           http.ProxyFromEnvironment,
+		Proxy:           proxy,
 	}
 	h := &http.Client{
 		Transport: tr,
@@ -109,6 +168,63 @@ func NewHTTPClientWithTimeout(t time.Duration) *http.Client {
 	return h
 }
 
+// SetExchangeHTTPProxy registers an HTTP client for the exchange which routes
+// requests through proxyURL, for exchanges only reachable from certain
+// regions. An empty proxyURL removes the registration so the exchange falls
+// back to the global client. A timeout of zero uses the default timeout
+func SetExchangeHTTPProxy(exchName, proxyURL string, t time.Duration) error {
+	if exchName == "" {
+		return errExchangeNameUnset
+	}
+	exchName = strings.ToLower(exchName)
+	if proxyURL == "" {
+		exchangeClientsMtx.Lock()
+		delete(exchangeClients, exchName)
+		exchangeClientsMtx.Unlock()
+		return nil
+	}
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidProxyURL, err)
+	}
+	if proxy.Scheme == "" || proxy.Host == "" {
+		return fmt.Errorf("%w: %s", errInvalidProxyURL, RedactURLQuery(proxyURL))
+	}
+	if t <= 0 {
+		t = defaultTimeout
+	}
+	client := newHTTPClient(t, http.ProxyURL(proxy))
+	exchangeClientsMtx.Lock()
+	exchangeClients[exchName] = client
+	exchangeClientsMtx.Unlock()
+	return nil
+}
+
+// GetExchangeHTTPClient returns the HTTP client registered for the exchange
+// by SetExchangeHTTPProxy, or the global client which uses the environment
+// proxy settings when none is registered. The global client is created with
+// the default timeout if it has not been set
+func GetExchangeHTTPClient(exchName string) *http.Client {
+	exchangeClientsMtx.RLock()
+	client, ok := exchangeClients[strings.ToLower(exchName)]
+	exchangeClientsMtx.RUnlock()
+	if ok {
+		return client
+	}
+	m.RLock()
+	client = _HTTPClient
+	m.RUnlock()
+	if client != nil {
+		return client
+	}
+	m.Lock()
+	defer m.Unlock()
+	if _HTTPClient == nil {
+		_HTTPClient = NewHTTPClientWithTimeout(defaultTimeout)
+	}
+	return _HTTPClient
+}
+
 // StringSliceDifference concatenates slices together based on its index and
 // returns an individual string array
 func StringSliceDifference(slice1, slice2 []string) []string {
@@ -180,20 +296,46 @@ func IsEnabled(isEnabled bool) string {
 	return "Disabled"
 }
 
+// CryptoAddressValidator reports whether address is valid for a currency on
+// the given network, an empty network means the currency's default network
+type CryptoAddressValidator func(address, network string) (bool, error)
+
+// RegisterCryptoAddressValidator registers the address validator used by
+// IsValidCryptoAddress for symbol, replacing any existing validator so
+// built-in ones can be overridden
+func RegisterCryptoAddressValidator(symbol string, fn CryptoAddressValidator) error {
+	if symbol == "" {
+		return errEmptyCryptoSymbol
+	}
+	if fn == nil {
+		return fmt.Errorf("%s validator %w", symbol, ErrNilPointer)
+	}
+	addressValidatorsMtx.Lock()
+	addressValidators[strings.ToLower(symbol)] = fn
+	addressValidatorsMtx.Unlock()
+	return nil
+}
+
+// regexpAddressValidator returns a validator matching addresses against
+// pattern regardless of network
+func regexpAddressValidator(pattern string) CryptoAddressValidator {
+	re := regexp.MustCompile(pattern)
+	return func(address, _ string) (bool, error) {
+		return re.MatchString(address), nil
+	}
+}
+
 // IsValidCryptoAddress validates your cryptocurrency address string using the
-// regexp package // Validation issues occurring because "3" is contained in
-// litecoin and Bitcoin addresses - non-fatal
+// validator registered for the currency // Validation issues occurring
+// because "3" is contained in litecoin and Bitcoin addresses - non-fatal
 func IsValidCryptoAddress(address, crypto string) (bool, error) {
-	switch strings.ToLower(crypto) {
-	case "btc":
-		return regexp.MatchString("^(bc1|[13])[a-zA-HJ-NP-Z0-9]{25,90}$", address)
-	case "ltc":
-		return regexp.MatchString("^[L3M][a-km-zA-HJ-NP-Z1-9]{25,34}$", address)
-	case "eth":
-		return regexp.MatchString("^0x[a-km-z0-9]{40}$", address)
-	default:
+	addressValidatorsMtx.RLock()
+	fn, ok := addressValidators[strings.ToLower(crypto)]
+	addressValidatorsMtx.RUnlock()
+	if !ok {
 		return false, fmt.Errorf("%w %s", errInvalidCryptoCurrency, crypto)
 	}
+	return fn(address, "")
 }
 
 // YesOrNo returns a boolean variable to check if input is "y" or "yes"
@@ -204,9 +346,41 @@ func YesOrNo(input string) bool {
 	return false
 }
 
+// HTTPRequestOptions holds the optional parts of a SendHTTPRequestWithOptions
+// request
+type HTTPRequestOptions struct {
+	Headers map[string]string
+	Body    io.Reader
+	Verbose bool
+	// ExpectedContentType, when set, is the media type the response must
+	// have, e.g. "application/json". Parameters such as charset are ignored
+	ExpectedContentType string
+	// Exchange, when set, sends the request with the exchange's client from
+	// GetExchangeHTTPClient so a proxy registered for it is used
+	Exchange string
+	// RequireSuccessStatus returns an error wrapping ErrUnexpectedHTTPStatus
+	// for a response without a 2xx status
+	RequireSuccessStatus bool
+}
+
 // SendHTTPRequest sends a request using the http package and returns the body
 // contents
 func SendHTTPRequest(ctx context.Context, method, urlPath string, headers map[string]string, body io.Reader, verbose bool) ([]byte, error) {
+	return SendHTTPRequestWithOptions(ctx, method, urlPath, &HTTPRequestOptions{
+		Headers: headers,
+		Body:    body,
+		Verbose: verbose,
+	})
+}
+
+// SendHTTPRequestWithOptions sends a request using the http package and
+// returns the body contents. If the body cannot be fully read the contents
+// read so far are returned with the error
+func SendHTTPRequestWithOptions(ctx context.Context, method, urlPath string, opts *HTTPRequestOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &HTTPRequestOptions{}
+	}
+	headers, body, verbose := opts.Headers, opts.Body, opts.Verbose
 	method = strings.ToUpper(method)
 
 	if method != http.MethodOptions && method != http.MethodGet &&
@@ -216,9 +390,12 @@ func SendHTTPRequest(ctx context.Context, method, urlPath string, headers map[st
 		return nil, errors.New("invalid HTTP method specified")
 	}
 
+	ctx, done := trackRequest(ctx, method, urlPath)
+	defer done()
+
 	req, err := http.NewRequestWithContext(ctx, method, urlPath, body)
 	if err != nil {
-		return nil, err
+		return nil, requestError(method, urlPath, 0, err)
 	}
 
 	for k, v := range headers {
@@ -226,13 +403,19 @@ func SendHTTPRequest(ctx context.Context, method, urlPath string, headers map[st
 	}
 
 	if verbose {
-		log.Debugf(log.Global, "Request path: %s", urlPath)
+		// Verbose output is redacted as headers, query parameters and
+		// bodies carry API keys and signatures
+		log.Debugf(log.Global, "Request path: %s", log.Redact(urlPath))
 		for k, d := range req.Header {
-			log.Debugf(log.Global, "Request header [%s]: %s", k, d)
+			if isSensitiveName(k) {
+				log.Debugf(log.Global, "Request header [%s]: %s", k, log.RedactedValue)
+				continue
+			}
+			log.Debugf(log.Global, "Request header [%s]: %s", k, log.Redact(fmt.Sprint(d)))
 		}
 		log.Debugf(log.Global, "Request type: %s", method)
 		if body != nil {
-			log.Debugf(log.Global, "Request body: %v", body)
+			log.Debugf(log.Global, "Request body: %s", log.Redact(fmt.Sprintf("%v", body)))
 		}
 	}
 
@@ -240,20 +423,11 @@ func SendHTTPRequest(ctx context.Context, method, urlPath string, headers map[st
 	if _HTTPUserAgent != "" && req.Header.Get("User-Agent") == "" {
 		req.Header.Add("User-Agent", _HTTPUserAgent)
 	}
-
-	if _HTTPClient == nil {
-		m.RUnlock()
-		m.Lock()
-		// Set *http.Client with default timeout if not populated.
-		_HTTPClient = NewHTTPClientWithTimeout(defaultTimeout)
-		m.Unlock()
-		m.RLock()
-	}
-
-	resp, err := _HTTPClient.Do(req)
 	m.RUnlock()
+
+	resp, err := GetExchangeHTTPClient(opts.Exchange).Do(req)
 	if err != nil {
-		return nil, err
+		return nil, requestError(method, urlPath, 0, err)
 	}
 	defer resp.Body.Close()
 
@@ -263,10 +437,188 @@ func SendHTTPRequest(ctx context.Context, method, urlPath string, headers map[st
 		log.Debugf(log.Global, "HTTP status: %s, Code: %v",
 			resp.Status,
 			resp.StatusCode)
-		log.Debugf(log.Global, "Raw response: %s", string(contents))
+		log.Debugf(log.Global, "Raw response: %s", log.Redact(string(contents)))
+	}
+	if err != nil {
+		return contents, requestError(method, urlPath, resp.StatusCode,
+			fmt.Errorf("reading response body after %d bytes: %w", len(contents), err))
+	}
+
+	if opts.ExpectedContentType != "" {
+		err = checkContentType(resp.Header.Get("Content-Type"), opts.ExpectedContentType, contents)
+		if err != nil {
+			return contents, requestError(method, urlPath, resp.StatusCode, err)
+		}
+	}
+	if opts.RequireSuccessStatus &&
+		(resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices) {
+		// Contents are still returned as error responses often carry a
+		// reason in the body
+		return contents, requestError(method, urlPath, resp.StatusCode,
+			fmt.Errorf("%w: %s, body: %s", ErrUnexpectedHTTPStatus, resp.Status, bodySnippet(contents)))
 	}
+	return contents, nil
+}
+
+// requestError adds the method, redacted URL and, once a response has been
+// received, the status code to a SendHTTPRequest error so it is actionable
+// without verbose logging. Request bodies are never included
+func requestError(method, urlPath string, status int, err error) error {
+	safeURL := RedactURLQuery(urlPath)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// The client error already names the method and URL, swap in the
+		// redacted URL rather than repeating it
+		urlErr.URL = safeURL
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("%s %s: status %d: %w", method, safeURL, status, err)
+	}
+	return fmt.Errorf("%s %s: %w", method, safeURL, err)
+}
+
+// sensitiveNameParts are substrings of query parameter and header names
+// whose values are redacted from logged URLs and verbose output
+var sensitiveNameParts = []string{"sign", "key", "secret", "token", "pass", "auth"}
+
+// RedactURLQuery returns the URL with the values of query parameters which
+// may hold credentials or signatures replaced, an unparsable URL has its
+// whole query dropped
+func RedactURLQuery(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+			return rawURL[:i]
+		}
+		return rawURL
+	}
+	if u.RawQuery == "" {
+		return rawURL
+	}
+	values := u.Query()
+	for k := range values {
+		if isSensitiveName(k) {
+			values[k] = []string{redactedQueryValue}
+		}
+	}
+	u.RawQuery = values.Encode()
+	return u.String()
+}
+
+// isSensitiveName returns whether a query parameter or header name looks
+// like it holds credentials or a signature
+func isSensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for x := range sensitiveNameParts {
+		if strings.Contains(name, sensitiveNameParts[x]) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContentType returns ErrUnexpectedContentType, with the actual content
+// type and the start of the body, when the media types do not match
+func checkContentType(actual, expected string, contents []byte) error {
+	actualType, _, err := mime.ParseMediaType(actual)
+	if err == nil {
+		expectedType, _, parseErr := mime.ParseMediaType(expected)
+		if parseErr != nil {
+			expectedType = expected
+		}
+		if strings.EqualFold(actualType, expectedType) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: expected %s received %q, body: %s",
+		ErrUnexpectedContentType, expected, actual, bodySnippet(contents))
+}
+
+// bodySnippet returns the redacted start of a response body for use in
+// errors, as error responses often echo back keys and tokens
+func bodySnippet(contents []byte) string {
+	if len(contents) > bodySnippetLen {
+		contents = contents[:bodySnippetLen]
+	}
+	return log.Redact(string(bytes.TrimSpace(contents)))
+}
+
+// RequestInfo describes an in flight SendHTTPRequest call
+type RequestInfo struct {
+	ID     uint64
+	Method string
+	// URL excludes the query string as it may hold signed parameters
+	URL     string
+	Started time.Time
+}
+
+type inFlightRequest struct {
+	info   RequestInfo
+	cancel context.CancelFunc
+}
+
+// requestRegistry tracks in flight SendHTTPRequest calls so they can be
+// listed and cancelled
+type requestRegistry struct {
+	mtx      sync.Mutex
+	nextID   uint64
+	requests map[uint64]*inFlightRequest
+}
+
+var inFlight = requestRegistry{requests: make(map[uint64]*inFlightRequest)}
+
+// trackRequest registers a request and returns its cancellable context along
+// with a func which must be called on completion to deregister it
+func trackRequest(ctx context.Context, method, urlPath string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	if i := strings.IndexByte(urlPath, '?'); i >= 0 {
+		urlPath = urlPath[:i]
+	}
+	inFlight.mtx.Lock()
+	inFlight.nextID++
+	id := inFlight.nextID
+	inFlight.requests[id] = &inFlightRequest{
+		info: RequestInfo{
+			ID:      id,
+			Method:  method,
+			URL:     urlPath,
+			Started: time.Now(),
+		},
+		cancel: cancel,
+	}
+	inFlight.mtx.Unlock()
+	return ctx, func() {
+		inFlight.mtx.Lock()
+		delete(inFlight.requests, id)
+		inFlight.mtx.Unlock()
+		cancel()
+	}
+}
+
+// InFlightRequests returns the SendHTTPRequest calls which have not yet
+// completed, oldest first
+func InFlightRequests() []RequestInfo {
+	inFlight.mtx.Lock()
+	requests := make([]RequestInfo, 0, len(inFlight.requests))
+	for _, r := range inFlight.requests {
+		requests = append(requests, r.info)
+	}
+	inFlight.mtx.Unlock()
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].ID < requests[j].ID
+	})
+	return requests
+}
 
-	return contents, err
+// CancelAllRequests cancels the context of every in flight SendHTTPRequest
+// call, which then return a context cancelled error
+func CancelAllRequests() {
+	inFlight.mtx.Lock()
+	defer inFlight.mtx.Unlock()
+	for _, r := range inFlight.requests {
+		r.cancel()
+	}
 }
 
 // EncodeURLValues concatenates url values onto a url string and returns a
@@ -279,35 +631,134 @@ func EncodeURLValues(urlPath string, values url.Values) string {
 	return u
 }
 
-// ExtractHost returns the hostname out of a string
+// ExtractHost returns the hostname out of a string, IPv6 literals are
+// returned without brackets e.g. [::1]:8080 returns ::1
 func ExtractHost(address string) string {
-	host := strings.Split(address, ":")[0]
+	host, _ := splitHostPort(address)
 	if host == "" {
 		return "localhost"
 	}
 	return host
 }
 
-// ExtractPort returns the port name out of a string
+// ExtractPort returns the port name out of a string, defaulting to 80 when
+// no port is present
 func ExtractPort(host string) int {
-	portStrs := strings.Split(host, ":")
-	if len(portStrs) == 1 {
+	_, portStr := splitHostPort(host)
+	if portStr == "" {
 		return 80
 	}
-	port, _ := strconv.Atoi(portStrs[1])
+	port, _ := strconv.Atoi(portStr)
 	return port
 }
 
-// GetURIPath returns the path of a URL given a URI
+// splitHostPort splits an address into its host and port, accepting
+// addresses without a port and bare or bracketed IPv6 literals. Addresses
+// net.SplitHostPort cannot parse are split on the first colon
+func splitHostPort(address string) (host, port string) {
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		return host, port
+	}
+	unbracketed := strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	if _, err := netip.ParseAddr(unbracketed); err == nil {
+		return unbracketed, ""
+	}
+	host, port, _ = strings.Cut(address, ":")
+	return host, port
+}
+
+// GetURIPath returns the escaped path of a URI followed by its raw query
+// string, if present. The query is returned verbatim so parameter ordering is
+// preserved for request signing and fragments are dropped. Accepted inputs:
+//
+//	https://api.x.com/v1/x?y=1 returns /v1/x?y=1
+//	api.x.com/v1/x?y=1         returns /v1/x?y=1 (schemeless, host detected)
+//	api/v1/x?y=1               returns api/v1/x?y=1 (relative path)
+//
+// Input which url.Parse rejects falls back to a plain string split so a
+// usable path is not discarded. An empty string is only returned when there
+// is no path or query to extract.
 func GetURIPath(uri string) string {
+	uri = strings.TrimSpace(uri)
+	if uri == "" {
+		return ""
+	}
+	if !strings.Contains(uri, "://") &&
+		!strings.HasPrefix(uri, "//") &&
+		isHostSegment(firstURISegment(uri)) {
+		uri = "//" + uri
+	}
 	urip, err := url.Parse(uri)
 	if err != nil {
-		return ""
+		return rawURIPath(uri)
+	}
+	path := urip.EscapedPath()
+	if urip.Opaque != "" {
+		path = urip.Opaque
 	}
-	if urip.RawQuery != "" {
-		return urip.Path + "?" + urip.RawQuery
+	if urip.RawQuery != "" || urip.ForceQuery {
+		return path + "?" + urip.RawQuery
 	}
-	return urip.Path
+	return path
+}
+
+// firstURISegment returns the portion of a schemeless URI before the first
+// path, query or fragment delimiter
+func firstURISegment(uri string) string {
+	if i := strings.IndexAny(uri, "/?#"); i != -1 {
+		return uri[:i]
+	}
+	return uri
+}
+
+// isHostSegment reports whether the leading segment of a schemeless URI
+// looks like a host (localhost, an IP, a host with a port or a dotted domain
+// ending in an alphabetic label) rather than the first element of a path
+func isHostSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if host, port, err := net.SplitHostPort(segment); err == nil {
+		if _, err = strconv.ParseUint(port, 10, 16); err != nil {
+			return false
+		}
+		segment = host
+	}
+	if strings.EqualFold(segment, "localhost") || net.ParseIP(segment) != nil {
+		return true
+	}
+	i := strings.LastIndexByte(segment, '.')
+	if i <= 0 || i == len(segment)-1 {
+		return false
+	}
+	for _, r := range segment[i+1:] {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// rawURIPath extracts the path and query from a URI which could not be
+// parsed, by stripping any scheme and host and dropping the fragment
+func rawURIPath(uri string) string {
+	hasHost := strings.HasPrefix(uri, "//")
+	if i := strings.Index(uri, "://"); i != -1 {
+		uri = uri[i+3:]
+		hasHost = true
+	}
+	if hasHost {
+		uri = strings.TrimPrefix(uri, "//")
+		i := strings.IndexAny(uri, "/?#")
+		if i == -1 {
+			return ""
+		}
+		uri = uri[i:]
+	}
+	if i := strings.IndexByte(uri, '#'); i != -1 {
+		uri = uri[:i]
+	}
+	return uri
 }
 
 // GetExecutablePath returns the executables launch path
@@ -378,6 +829,49 @@ func SplitStringSliceByLimit(in []string, limit uint) [][]string {
 	return sliceSlice
 }
 
+// SplitCurrencyPair splits a currency pair string into its base and quote
+// currencies. When delimiter is empty the pair is split on the longest known
+// quote currency suffix, so BTCBUSD is quoted in BUSD rather than USD. If
+// different suffixes of the same length match an error is returned. The
+// returned currencies keep the casing of the input
+func SplitCurrencyPair(pair, delimiter string) (base, quote string, err error) {
+	if pair == "" {
+		return "", "", fmt.Errorf("%w: empty pair", errInvalidCurrencyPair)
+	}
+	if delimiter != "" {
+		parts := strings.Split(pair, delimiter)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return "", "", fmt.Errorf("%w: %q with delimiter %q", errInvalidCurrencyPair, pair, delimiter)
+		}
+		return parts[0], parts[1], nil
+	}
+
+	upper := strings.ToUpper(pair)
+	var matches []string
+	for x := range knownQuoteCurrencies {
+		suffix := knownQuoteCurrencies[x]
+		if len(upper) <= len(suffix) || !strings.HasSuffix(upper, suffix) {
+			continue
+		}
+		switch {
+		case len(matches) == 0 || len(suffix) > len(matches[0]):
+			matches = []string{suffix}
+		case len(suffix) == len(matches[0]) && suffix != matches[0]:
+			matches = append(matches, suffix)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", "", fmt.Errorf("%w: %q has no known quote currency", errInvalidCurrencyPair, pair)
+	case 1:
+		split := len(pair) - len(matches[0])
+		return pair[:split], pair[split:], nil
+	default:
+		return "", "", fmt.Errorf("%w: %q could be quoted in any of %s",
+			errAmbiguousCurrencyPair, pair, strings.Join(matches, ", "))
+	}
+}
+
 // InArray checks if _val_ belongs to _array_
 func InArray(val, array interface{}) (exists bool, index int) {
 	exists = false
@@ -402,18 +896,114 @@ func InArray(val, array interface{}) (exists bool, index int) {
 // Errors defines multiple errors
 type Errors []error
 
-// Error implements error interface
+// Error implements error interface, nil entries are skipped
 func (e Errors) Error() string {
-	if len(e) == 0 {
-		return ""
-	}
 	var r string
 	for i := range e {
+		if e[i] == nil {
+			continue
+		}
 		r += e[i].Error() + ", "
 	}
+	if r == "" {
+		return ""
+	}
 	return r[:len(r)-2]
 }
 
+// ErrorOrNil returns nil when there are no non nil errors, so an Errors
+// value can be returned directly from a func returning error
+func (e Errors) ErrorOrNil() error {
+	collected := CollectErrors(e...)
+	if len(collected) == 0 {
+		return nil
+	}
+	return collected
+}
+
+// CollectErrors returns the supplied errors without nils, with any nested
+// Errors flattened into the result
+func CollectErrors(errs ...error) Errors {
+	var collected Errors
+	for i := range errs {
+		switch e := errs[i].(type) {
+		case nil:
+		case Errors:
+			collected = append(collected, CollectErrors(e...)...)
+		default:
+			collected = append(collected, e)
+		}
+	}
+	return collected
+}
+
+// Error codes for the common error set, these are stable and safe to return
+// to API consumers
+const (
+	ErrCodeNotYetImplemented    = "NOT_YET_IMPLEMENTED"
+	ErrCodeFunctionNotSupported = "FUNCTION_NOT_SUPPORTED"
+	ErrCodeDateUnset            = "DATE_UNSET"
+	ErrCodeStartAfterEnd        = "START_AFTER_END"
+	ErrCodeStartEqualsEnd       = "START_EQUALS_END"
+	ErrCodeStartAfterTimeNow    = "START_AFTER_TIME_NOW"
+	ErrCodeNilPointer           = "NIL_POINTER"
+)
+
+// commonErrorCodes maps the exported common errors to their codes
+var commonErrorCodes = []CodedError{
+	{Code: ErrCodeNotYetImplemented, Err: ErrNotYetImplemented},
+	{Code: ErrCodeFunctionNotSupported, Err: ErrFunctionNotSupported},
+	{Code: ErrCodeDateUnset, Err: ErrDateUnset},
+	{Code: ErrCodeStartAfterEnd, Err: ErrStartAfterEnd},
+	{Code: ErrCodeStartEqualsEnd, Err: ErrStartEqualsEnd},
+	{Code: ErrCodeStartAfterTimeNow, Err: ErrStartAfterTimeNow},
+	{Code: ErrCodeNilPointer, Err: ErrNilPointer},
+}
+
+// CodedError pairs an error with a stable machine readable code
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+// NewCodedError returns err wrapped with the supplied code
+func NewCodedError(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CodedError{Code: code, Err: err}
+}
+
+// Error implements error interface
+func (c *CodedError) Error() string {
+	return c.Err.Error()
+}
+
+// Unwrap returns the underlying error so errors.Is and errors.As keep
+// matching against it
+func (c *CodedError) Unwrap() error {
+	return c.Err
+}
+
+// Code returns the code of the first CodedError in the error chain or, if
+// there is none, the code of the common error it wraps. An empty string is
+// returned when no code is known.
+func Code(err error) string {
+	if err == nil {
+		return ""
+	}
+	var coded *CodedError
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	for i := range commonErrorCodes {
+		if errors.Is(err, commonErrorCodes[i].Err) {
+			return commonErrorCodes[i].Code
+		}
+	}
+	return ""
+}
+
 // StartEndTimeCheck provides some basic checks which occur
 // frequently in the codebase
 func StartEndTimeCheck(start, end time.Time) error {
@@ -435,3 +1025,214 @@ func StartEndTimeCheck(start, end time.Time) error {
 
 	return nil
 }
+
+// SleepCtx pauses for d or until ctx is done, whichever is first. The
+// context's error is returned when the sleep was cut short
+func SleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Backoff produces exponentially increasing retry delays. Zero fields use
+// the package defaults and no jitter. Backoff is not safe for concurrent use
+type Backoff struct {
+	// Base is the first delay
+	Base time.Duration
+	// Factor multiplies the delay after every attempt
+	Factor float64
+	// Max caps the delay
+	Max time.Duration
+	// Jitter is the fraction, between 0 and 1, each delay is randomly
+	// reduced by so retries from many callers spread out
+	Jitter float64
+
+	attempt int
+}
+
+// Next returns the delay before the next attempt and advances the backoff
+func (b *Backoff) Next() time.Duration {
+	base, factor, maxDelay := b.Base, b.Factor, b.Max
+	if base <= 0 {
+		base = DefaultBackoffBase
+	}
+	if factor < 1 {
+		factor = DefaultBackoffFactor
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultBackoffMax
+	}
+
+	delay := float64(base) * math.Pow(factor, float64(b.attempt))
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	} else {
+		b.attempt++
+	}
+	if b.Jitter > 0 {
+		jitter := math.Min(b.Jitter, 1)
+		delay -= delay * jitter * rand.Float64() // nolint:gosec // jitter does not need a secure source
+	}
+	return time.Duration(delay)
+}
+
+// Reset starts the delays again from the base
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// RetryWithBackoff calls fn until it succeeds, maxAttempts calls have failed
+// or ctx is done, sleeping for the next backoff delay between calls. A nil
+// backoff uses the defaults
+func RetryWithBackoff(ctx context.Context, maxAttempts int, fn func() error, bo *Backoff) error {
+	if maxAttempts <= 0 {
+		return fmt.Errorf("%w: %d", errInvalidMaxAttempts, maxAttempts)
+	}
+	if fn == nil {
+		return fmt.Errorf("retry func: %w", ErrNilPointer)
+	}
+	if bo == nil {
+		bo = &Backoff{}
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= maxAttempts {
+			return fmt.Errorf("%w after %d attempts: %v", ErrRetriesExhausted, attempt, err)
+		}
+		if ctxErr := SleepCtx(ctx, bo.Next()); ctxErr != nil {
+			return fmt.Errorf("%w, last error: %v", ctxErr, err)
+		}
+	}
+}
+
+// ClockJump describes the wall clock moving further than the monotonic clock
+// between two checks, as happens when the host suspends and resumes or the
+// system time is stepped
+type ClockJump struct {
+	DetectedAt time.Time
+	// Jump is the unexplained wall clock movement, negative when the clock
+	// was set backwards
+	Jump time.Duration
+}
+
+// ClockJumpDetector periodically compares wall clock and monotonic clock
+// progress. The monotonic clock does not advance while the host is
+// suspended on Linux and macOS, so a suspend shows up as a wall clock jump
+type ClockJumpDetector struct {
+	interval    time.Duration
+	threshold   time.Duration
+	wallNow     func() time.Time
+	monoNow     func() time.Duration
+	lastWall    time.Time
+	lastMono    time.Duration
+	jumps       uint64
+	subscribers []func(ClockJump)
+	mtx         sync.Mutex
+}
+
+// NewClockJumpDetector returns a detector which checks every interval and
+// reports jumps larger than threshold
+func NewClockJumpDetector(interval, threshold time.Duration) (*ClockJumpDetector, error) {
+	if interval <= 0 || threshold <= 0 {
+		return nil, errInvalidClockJumpParams
+	}
+	start := time.Now()
+	return newClockJumpDetector(interval, threshold,
+		func() time.Time { return time.Now().Round(0) },
+		func() time.Duration { return time.Since(start) }), nil
+}
+
+// newClockJumpDetector allows the clock pair to be replaced in tests, wallNow
+// must not carry a monotonic reading
+func newClockJumpDetector(interval, threshold time.Duration, wallNow func() time.Time, monoNow func() time.Duration) *ClockJumpDetector {
+	return &ClockJumpDetector{
+		interval:  interval,
+		threshold: threshold,
+		wallNow:   wallNow,
+		monoNow:   monoNow,
+		lastWall:  wallNow(),
+		lastMono:  monoNow(),
+	}
+}
+
+// Subscribe registers fn to be called with every detected jump
+func (d *ClockJumpDetector) Subscribe(fn func(ClockJump)) {
+	d.mtx.Lock()
+	d.subscribers = append(d.subscribers, fn)
+	d.mtx.Unlock()
+}
+
+// Jumps returns the number of jumps detected
+func (d *ClockJumpDetector) Jumps() uint64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return d.jumps
+}
+
+// Run checks for clock jumps every interval until ctx is done
+func (d *ClockJumpDetector) Run(ctx context.Context) {
+	for range NewManagedTicker(ctx, d.interval) {
+		d.check()
+	}
+}
+
+// check compares clock progress since the previous check, logging and
+// notifying subscribers when the difference exceeds the threshold
+func (d *ClockJumpDetector) check() bool {
+	d.mtx.Lock()
+	wall, mono := d.wallNow(), d.monoNow()
+	jump := wall.Sub(d.lastWall) - (mono - d.lastMono)
+	d.lastWall, d.lastMono = wall, mono
+	if jump < d.threshold && jump > -d.threshold {
+		d.mtx.Unlock()
+		return false
+	}
+	d.jumps++
+	subscribers := make([]func(ClockJump), len(d.subscribers))
+	copy(subscribers, d.subscribers)
+	d.mtx.Unlock()
+
+	log.Warnf(log.Global, "Clock jump of %s detected, the host may have been suspended\n", jump)
+	event := ClockJump{DetectedAt: wall, Jump: jump}
+	for x := range subscribers {
+		subscribers[x](event)
+	}
+	return true
+}
+
+// NewManagedTicker returns a channel which ticks every d until ctx is done,
+// at which point the channel is closed so ranging loops exit. As with
+// time.Ticker, ticks are dropped for slow receivers and d must be above zero
+func NewManagedTicker(ctx context.Context, d time.Duration) <-chan time.Time {
+	t := time.NewTicker(d)
+	c := make(chan time.Time, 1)
+	go func() {
+		defer func() {
+			t.Stop()
+			close(c)
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case tick := <-t.C:
+				select {
+				case c <- tick:
+				default:
+				}
+			}
+		}
+	}()
+	return c
+}
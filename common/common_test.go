@@ -0,0 +1,777 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/common/crypto"
+	"github.com/zhiwei-w-luo/gotradebot/common/testhelpers"
+	"github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+func TestGetURIPath(t *testing.T) {
+	t.Parallel()
+	testTable := map[string]string{
+		"https://api.gdax.com/accounts":            "/accounts",
+		"https://api.kraken.com/0/private/Balance": "/0/private/Balance",
+		"https://api.gdax.com/accounts?a=1&b=2":    "/accounts?a=1&b=2",
+		"https://api.x.com/v1/x?z=3&a=1&m=2#frag":  "/v1/x?z=3&a=1&m=2",
+		"http://localhost:8080/v1/x":               "/v1/x",
+		"//api.x.com/v1/x?y=1":                     "/v1/x?y=1",
+		"api.x.com/v1/x?y=1":                       "/v1/x?y=1",
+		"localhost:8080/v1/x":                      "/v1/x",
+		"127.0.0.1:9050/v1/getinfo":                "/v1/getinfo",
+		"api/v1/x?y=1":                             "api/v1/x?y=1",
+		"/api/v1/x?y=1":                            "/api/v1/x?y=1",
+		"v1.2/items?b=2&a=1":                       "v1.2/items?b=2&a=1",
+		"https://api.x.com/v1/a%2Fb?sig=abc":       "/v1/a%2Fb?sig=abc",
+		"https://api.x.com":                        "",
+		"api.x.com":                                "",
+		"":                                         "",
+		"http://[::1":                              "",
+		"%zz/path?y=1":                             "%zz/path?y=1",
+		"https://api.x.com/%zz/path?y=1#frag":      "/%zz/path?y=1",
+		"https://api.bitfinex.com/v1/order/new?symbol=btcusd&x": "/v1/order/new?symbol=btcusd&x",
+	}
+	for input, expected := range testTable {
+		if path := GetURIPath(input); path != expected {
+			t.Errorf("GetURIPath(%q) received: %q but expected: %q", input, path, expected)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	t.Parallel()
+	if code := Code(nil); code != "" {
+		t.Fatalf("received: %q but expected no code", code)
+	}
+	if code := Code(errors.New("unknown")); code != "" {
+		t.Fatalf("received: %q but expected no code", code)
+	}
+
+	err := StartEndTimeCheck(time.Time{}, time.Now())
+	if code := Code(err); code != ErrCodeDateUnset {
+		t.Fatalf("received: %q but expected: %q", code, ErrCodeDateUnset)
+	}
+
+	err = fmt.Errorf("wrapped: %w", ErrStartAfterEnd)
+	if code := Code(err); code != ErrCodeStartAfterEnd {
+		t.Fatalf("received: %q but expected: %q", code, ErrCodeStartAfterEnd)
+	}
+
+	err = NewCodedError("ORDER_REJECTED", ErrNilPointer)
+	if code := Code(fmt.Errorf("submit: %w", err)); code != "ORDER_REJECTED" {
+		t.Fatalf("received: %q but expected: %q", code, "ORDER_REJECTED")
+	}
+	if !errors.Is(err, ErrNilPointer) {
+		t.Fatal("expected coded error to match the error it wraps")
+	}
+	if err.Error() != ErrNilPointer.Error() {
+		t.Fatalf("received: %q but expected: %q", err, ErrNilPointer)
+	}
+	if NewCodedError("NOTHING", nil) != nil {
+		t.Fatal("expected nil error to remain nil")
+	}
+}
+
+func TestSplitCurrencyPair(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		pair, delimiter string
+		base, quote     string
+		err             error
+	}{
+		{pair: "BTC-USD", delimiter: "-", base: "BTC", quote: "USD"},
+		{pair: "eth/btc", delimiter: "/", base: "eth", quote: "btc"},
+		{pair: "DOGE_USDT", delimiter: "_", base: "DOGE", quote: "USDT"},
+		{pair: "BTCUSDT", base: "BTC", quote: "USDT"},
+		{pair: "ethbtc", base: "eth", quote: "btc"},
+		{pair: "XBTEUR", base: "XBT", quote: "EUR"},
+		{pair: "BTC-USD-PERP", delimiter: "-", err: errInvalidCurrencyPair},
+		{pair: "BTC-", delimiter: "-", err: errInvalidCurrencyPair},
+		{pair: "BTCUSD", delimiter: "-", err: errInvalidCurrencyPair},
+		{pair: "", delimiter: "-", err: errInvalidCurrencyPair},
+		{pair: "USD", err: errInvalidCurrencyPair},
+		{pair: "BTCXYZ", err: errInvalidCurrencyPair},
+		{pair: "BTCBUSD", base: "BTC", quote: "BUSD"},
+		{pair: "BTCTUSD", base: "BTC", quote: "TUSD"},
+		{pair: "ethusd", base: "eth", quote: "usd"},
+	}
+	for x := range testCases {
+		tc := testCases[x]
+		base, quote, err := SplitCurrencyPair(tc.pair, tc.delimiter)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%q received: %v but expected: %v", tc.pair, err, tc.err)
+		}
+		if base != tc.base || quote != tc.quote {
+			t.Errorf("%q received: %s/%s but expected: %s/%s", tc.pair, base, quote, tc.base, tc.quote)
+		}
+	}
+}
+
+func TestSleepCtx(t *testing.T) {
+	t.Parallel()
+	if err := SleepCtx(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*10, cancel)
+	start := time.Now()
+	if err := SleepCtx(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("received: %v but expected: %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancellation took %v to interrupt sleep", elapsed)
+	}
+
+	if err := SleepCtx(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("received: %v but expected: %v", err, context.Canceled)
+	}
+}
+
+func TestNewManagedTicker(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewManagedTicker(ctx, time.Millisecond)
+	if _, ok := <-c; !ok {
+		t.Fatal("expected a tick before cancellation")
+	}
+	cancel()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-c:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("ticker channel not closed after cancellation")
+		}
+	}
+}
+
+func TestIsValidCryptoAddress(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		address, crypto string
+		valid           bool
+		err             error
+	}{
+		{address: "1Mz7153HMuxXTuR2R1t78mGSdzaAtNbBWX", crypto: "bTC", valid: true},
+		{address: "0Mz7153HMuxXTuR2R1t78mGSdzaAtNbBWX", crypto: "btc"},
+		{address: "3CDJNfdWX8m2NwuGUV3nhXHXEeLygMXoAj", crypto: "ltc", valid: true},
+		{address: "LgY8ahfHRhvjVQC1zJnBhFMG5pCTMuKRqh", crypto: "ltc", valid: true},
+		{address: "0xb794f5ea0ba39494ce839613fffba74279579268", crypto: "eth", valid: true},
+		{address: "0xb794f5ea0ba39494ce839613fffba74279579268", crypto: "xyz", err: errInvalidCryptoCurrency},
+	}
+	for x := range testCases {
+		valid, err := IsValidCryptoAddress(testCases[x].address, testCases[x].crypto)
+		if !errors.Is(err, testCases[x].err) {
+			t.Fatalf("%s received: %v but expected: %v", testCases[x].address, err, testCases[x].err)
+		}
+		if valid != testCases[x].valid {
+			t.Errorf("%s received: %v but expected: %v", testCases[x].address, valid, testCases[x].valid)
+		}
+	}
+}
+
+func TestRegisterCryptoAddressValidator(t *testing.T) {
+	t.Parallel()
+	if err := RegisterCryptoAddressValidator("", nil); !errors.Is(err, errEmptyCryptoSymbol) {
+		t.Fatalf("received: %v but expected: %v", err, errEmptyCryptoSymbol)
+	}
+	if err := RegisterCryptoAddressValidator("tst", nil); !errors.Is(err, ErrNilPointer) {
+		t.Fatalf("received: %v but expected: %v", err, ErrNilPointer)
+	}
+
+	errBadChecksum := errors.New("bad checksum")
+	err := RegisterCryptoAddressValidator("TST", func(address, network string) (bool, error) {
+		if network != "" {
+			return false, fmt.Errorf("unexpected network %s", network)
+		}
+		if address == "tst-bad" {
+			return false, errBadChecksum
+		}
+		return address == "tst-valid", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, err := IsValidCryptoAddress("tst-valid", "tst"); err != nil || !valid {
+		t.Fatalf("received: %v %v but expected: true <nil>", valid, err)
+	}
+	if valid, err := IsValidCryptoAddress("tst-other", "TST"); err != nil || valid {
+		t.Fatalf("received: %v %v but expected: false <nil>", valid, err)
+	}
+	if _, err := IsValidCryptoAddress("tst-bad", "tst"); !errors.Is(err, errBadChecksum) {
+		t.Fatalf("received: %v but expected: %v", err, errBadChecksum)
+	}
+}
+
+func TestCancelAllRequests(t *testing.T) {
+	t.Parallel()
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := SendHTTPRequest(context.Background(), http.MethodGet, srv.URL+"/slow?signature=secret", nil, nil, false)
+		errC <- err
+	}()
+	<-started
+
+	var found bool
+	for _, r := range InFlightRequests() {
+		if r.URL == srv.URL+"/slow" && r.Method == http.MethodGet && !r.Started.IsZero() {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected request in %+v", InFlightRequests())
+	}
+
+	CancelAllRequests()
+	select {
+	case err := <-errC:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("received: %v but expected: %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("request not cancelled")
+	}
+	for _, r := range InFlightRequests() {
+		if r.URL == srv.URL+"/slow" {
+			t.Fatal("expected completed request to be removed from the registry")
+		}
+	}
+}
+
+// Not parallel as the shared HTTP client is replaced
+func TestSetHTTPClientWithTimeout(t *testing.T) {
+	if err := SetHTTPClientWithTimeout(-time.Second); !errors.Is(err, errCannotSetInvalidTimeout) {
+		t.Fatalf("received: %v but expected: %v", err, errCannotSetInvalidTimeout)
+	}
+	m.Lock()
+	original := _HTTPClient
+	m.Unlock()
+	defer func() {
+		m.Lock()
+		_HTTPClient = original
+		m.Unlock()
+	}()
+
+	if err := SetHTTPClientWithTimeout(time.Second * 7); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	m.Lock()
+	timeout := _HTTPClient.Timeout
+	m.Unlock()
+	if timeout != time.Second*7 {
+		t.Fatalf("received: %v but expected: %v", timeout, time.Second*7)
+	}
+}
+
+func TestExchangeHTTPProxy(t *testing.T) {
+	t.Parallel()
+	if err := SetExchangeHTTPProxy("", "http://127.0.0.1:1", 0); !errors.Is(err, errExchangeNameUnset) {
+		t.Fatalf("received: %v but expected: %v", err, errExchangeNameUnset)
+	}
+	if err := SetExchangeHTTPProxy("proxytest", "127.0.0.1", 0); !errors.Is(err, errInvalidProxyURL) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidProxyURL)
+	}
+
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.Host
+	}))
+	defer proxy.Close()
+
+	if err := SetExchangeHTTPProxy("ProxyTest", proxy.URL, time.Second*5); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	client := GetExchangeHTTPClient("proxytest")
+	if client.Timeout != time.Second*5 {
+		t.Errorf("received: %v but expected: %v", client.Timeout, time.Second*5)
+	}
+	resp, err := client.Get("http://geo-restricted.invalid/ping")
+	if err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	resp.Body.Close()
+	if host := <-proxied; host != "geo-restricted.invalid" {
+		t.Errorf("received: %s but expected: %s", host, "geo-restricted.invalid")
+	}
+
+	if GetExchangeHTTPClient("unproxied") == client {
+		t.Error("expected exchange without a proxy to use the global client")
+	}
+	if err := SetExchangeHTTPProxy("proxytest", "", 0); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	if GetExchangeHTTPClient("proxytest") == client {
+		t.Error("expected removed proxy to fall back to the global client")
+	}
+}
+
+// Not parallel as TestCancelAllRequests cancels every in flight request
+func TestSendHTTPRequestExchangeProxy(t *testing.T) {
+	proxy := testhelpers.NewStubServer()
+	defer proxy.Close()
+	proxy.Handle("/ping", testhelpers.Response{Body: []byte("proxied")})
+
+	if err := SetExchangeHTTPProxy("optionsproxy", proxy.URL, 0); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	defer func() {
+		if err := SetExchangeHTTPProxy("optionsproxy", "", 0); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	contents, err := SendHTTPRequestWithOptions(context.Background(), http.MethodGet,
+		"http://geo-restricted.invalid/ping", &HTTPRequestOptions{Exchange: "OptionsProxy"})
+	if err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	if string(contents) != "proxied" {
+		t.Fatalf("received: %s but expected: %s", contents, "proxied")
+	}
+	if hits := proxy.Hits("/ping"); hits != 1 {
+		t.Fatalf("received: %d but expected: %d", hits, 1)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	b := Backoff{Base: time.Millisecond * 10, Factor: 3, Max: time.Millisecond * 200}
+	expected := []time.Duration{
+		time.Millisecond * 10,
+		time.Millisecond * 30,
+		time.Millisecond * 90,
+		time.Millisecond * 200,
+		time.Millisecond * 200,
+	}
+	for x := range expected {
+		if d := b.Next(); d != expected[x] {
+			t.Fatalf("delay %d received: %v but expected: %v", x, d, expected[x])
+		}
+	}
+	b.Reset()
+	if d := b.Next(); d != expected[0] {
+		t.Fatalf("received: %v but expected: %v", d, expected[0])
+	}
+
+	var defaults Backoff
+	if d := defaults.Next(); d != DefaultBackoffBase {
+		t.Fatalf("received: %v but expected: %v", d, DefaultBackoffBase)
+	}
+	if d := defaults.Next(); d != DefaultBackoffBase*DefaultBackoffFactor {
+		t.Fatalf("received: %v but expected: %v", d, DefaultBackoffBase*DefaultBackoffFactor)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	t.Parallel()
+	b := Backoff{Base: time.Second, Factor: 1, Max: time.Second, Jitter: 0.25}
+	lower := time.Second - time.Second/4
+	for x := 0; x < 1000; x++ {
+		if d := b.Next(); d < lower || d > time.Second {
+			t.Fatalf("received: %v but expected between %v and %v", d, lower, time.Second)
+		}
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	t.Parallel()
+	errTest := errors.New("test error")
+	bo := &Backoff{Base: time.Millisecond, Max: time.Millisecond}
+
+	err := RetryWithBackoff(context.Background(), 0, func() error { return nil }, bo)
+	if !errors.Is(err, errInvalidMaxAttempts) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidMaxAttempts)
+	}
+	err = RetryWithBackoff(context.Background(), 1, nil, bo)
+	if !errors.Is(err, ErrNilPointer) {
+		t.Fatalf("received: %v but expected: %v", err, ErrNilPointer)
+	}
+
+	var calls int
+	err = RetryWithBackoff(context.Background(), 5, func() error {
+		if calls++; calls < 3 {
+			return errTest
+		}
+		return nil
+	}, bo)
+	if err != nil || calls != 3 {
+		t.Fatalf("received: %v %d calls but expected: %v 3 calls", err, calls, nil)
+	}
+
+	calls = 0
+	err = RetryWithBackoff(context.Background(), 3, func() error {
+		calls++
+		return errTest
+	}, bo)
+	if !errors.Is(err, ErrRetriesExhausted) || calls != 3 {
+		t.Fatalf("received: %v %d calls but expected: %v 3 calls", err, calls, ErrRetriesExhausted)
+	}
+
+	// Cancelling mid retry stops waiting on a long backoff straight away
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	start := time.Now()
+	err = RetryWithBackoff(ctx, 5, func() error {
+		calls++
+		cancel()
+		return errTest
+	}, &Backoff{Base: time.Minute})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("received: %v %d calls but expected: %v 1 call", err, calls, context.Canceled)
+	}
+	if time.Since(start) > time.Second*5 {
+		t.Fatal("expected cancellation to interrupt the backoff")
+	}
+}
+
+// Not parallel as TestCancelAllRequests cancels every in flight request
+func TestSendHTTPRequestWithOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, `{"ok":true}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html>down for maintenance</html>")
+	}))
+	defer srv.Close()
+
+	opts := &HTTPRequestOptions{ExpectedContentType: "application/json"}
+	contents, err := SendHTTPRequestWithOptions(context.Background(), http.MethodGet, srv.URL+"/json", opts)
+	if err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	if string(contents) != `{"ok":true}` {
+		t.Fatalf("received: %s but expected: %s", contents, `{"ok":true}`)
+	}
+
+	contents, err = SendHTTPRequestWithOptions(context.Background(), http.MethodGet, srv.URL+"/maintenance", opts)
+	if !errors.Is(err, ErrUnexpectedContentType) {
+		t.Fatalf("received: %v but expected: %v", err, ErrUnexpectedContentType)
+	}
+	if !strings.Contains(err.Error(), "text/html") || !strings.Contains(err.Error(), "down for maintenance") {
+		t.Errorf("expected content type and body snippet in error: %v", err)
+	}
+	if len(contents) == 0 {
+		t.Error("expected contents to be returned with the error")
+	}
+
+	// Without an expected content type existing behaviour is unchanged
+	_, err = SendHTTPRequestWithOptions(context.Background(), http.MethodGet, srv.URL+"/maintenance", nil)
+	if err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	_, err = SendHTTPRequest(context.Background(), http.MethodGet, srv.URL+"/maintenance", nil, nil, false)
+	if err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+}
+
+// Not parallel as TestCancelAllRequests cancels every in flight request
+func TestSendHTTPRequestErrorContext(t *testing.T) {
+	srv := testhelpers.NewStubServer()
+	srv.Handle("/ticker", testhelpers.Response{
+		Status:  http.StatusServiceUnavailable,
+		Headers: map[string]string{"Content-Type": "text/html"},
+	})
+	host := srv.Listener.Addr().String()
+	path := srv.URL + "/ticker?symbol=BTC&signature=topsecret"
+
+	opts := &HTTPRequestOptions{ExpectedContentType: "application/json"}
+	_, err := SendHTTPRequestWithOptions(context.Background(), http.MethodGet, path, opts)
+	if !errors.Is(err, ErrUnexpectedContentType) {
+		t.Fatalf("received: %v but expected: %v", err, ErrUnexpectedContentType)
+	}
+	for _, want := range []string{"GET", host, "status 503", "symbol=BTC"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "topsecret") {
+		t.Errorf("expected signature to be redacted: %v", err)
+	}
+
+	srv.Handle("/down", testhelpers.Response{
+		Status:  http.StatusServiceUnavailable,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    []byte(`{"error":"maintenance","apiKey":"echoedkey"}`),
+	})
+	// The status is only checked when required, existing callers still
+	// receive the contents of any response
+	contents, err := SendHTTPRequest(context.Background(), http.MethodGet, srv.URL+"/down", nil, nil, false)
+	if err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	if !strings.Contains(string(contents), "maintenance") {
+		t.Errorf("received: %s but expected the error response contents", contents)
+	}
+
+	opts.RequireSuccessStatus = true
+	contents, err = SendHTTPRequestWithOptions(context.Background(), http.MethodGet, srv.URL+"/down?signature=topsecret", opts)
+	if !errors.Is(err, ErrUnexpectedHTTPStatus) {
+		t.Fatalf("received: %v but expected: %v", err, ErrUnexpectedHTTPStatus)
+	}
+	for _, want := range []string{"GET", host, "status 503", "maintenance"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "topsecret") || strings.Contains(err.Error(), "echoedkey") {
+		t.Errorf("expected signature and echoed key to be redacted: %v", err)
+	}
+	if string(contents) != `{"error":"maintenance","apiKey":"echoedkey"}` {
+		t.Errorf("received: %s but expected the error response contents", contents)
+	}
+
+	srv.Close()
+	_, err = SendHTTPRequest(context.Background(), http.MethodGet, path, nil, nil, false)
+	if err == nil {
+		t.Fatal("expected error from closed server")
+	}
+	if !strings.Contains(err.Error(), host) || strings.Contains(err.Error(), "topsecret") {
+		t.Errorf("expected host without signature in error: %v", err)
+	}
+}
+
+type lockedBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
+
+// Not parallel as the global sub logger output is captured
+func TestSendHTTPRequestVerboseRedaction(t *testing.T) {
+	const (
+		apiKey    = "test-api-key-0123456789"
+		apiSecret = "test-api-secret-9876543210"
+		token     = "test-bearer-token-abcdef"
+	)
+	log.RegisterSensitiveValue(apiKey)
+	log.RegisterSensitiveValue(apiSecret)
+
+	srv := testhelpers.NewStubServer()
+	defer srv.Close()
+	srv.Handle("/order", testhelpers.Response{Body: []byte(`{"status":"ok","echo":"` + apiKey + `"}`)})
+
+	payload := "symbol=BTCUSDT&side=BUY&recvWindow=5000&secret=" + apiSecret
+	hmac, err := crypto.GetHMAC(crypto.HashSHA256, []byte(payload), []byte(apiSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := crypto.HexEncodeToString(hmac)
+
+	out := &lockedBuffer{}
+	levels := log.Global.GetLevels()
+	log.Global.SetOutput(out)
+	log.Global.SetLevels(log.Levels{Info: true, Debug: true, Warn: true, Error: true})
+	defer func() {
+		log.Global.SetOutput(os.Stdout)
+		log.Global.SetLevels(levels)
+	}()
+
+	_, err = SendHTTPRequest(context.Background(),
+		http.MethodPost,
+		srv.URL+"/order?timestamp=1&signature="+signature,
+		map[string]string{
+			"X-MBX-APIKEY":  apiKey,
+			"Authorization": "Bearer " + token,
+			"Content-Type":  "application/x-www-form-urlencoded",
+		},
+		strings.NewReader(payload+"&signature="+signature),
+		true)
+	if err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+
+	logged := out.String()
+	if !strings.Contains(logged, "/order") || !strings.Contains(logged, "application/x-www-form-urlencoded") {
+		t.Fatalf("expected verbose request output, received: %s", logged)
+	}
+	for _, secret := range []string{apiKey, apiSecret, token, signature} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("secret %q found in verbose output: %s", secret, logged)
+		}
+	}
+}
+
+func TestRedactURLQuery(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		in, expected string
+	}{
+		{"https://api.x/v1/time", "https://api.x/v1/time"},
+		{"https://api.x/v1/order?symbol=BTC", "https://api.x/v1/order?symbol=BTC"},
+		{"https://api.x/v1/order?apiKey=k&symbol=BTC&signature=s", "https://api.x/v1/order?apiKey=REDACTED&signature=REDACTED&symbol=BTC"},
+		{"https://api.x/v1/order?access_token=t", "https://api.x/v1/order?access_token=REDACTED"},
+		{"%zz?signature=s", "%zz"},
+	} {
+		if got := RedactURLQuery(tc.in); got != tc.expected {
+			t.Errorf("%s received: %s but expected: %s", tc.in, got, tc.expected)
+		}
+	}
+}
+
+func TestClockJumpDetector(t *testing.T) {
+	t.Parallel()
+	if _, err := NewClockJumpDetector(0, time.Second); !errors.Is(err, errInvalidClockJumpParams) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidClockJumpParams)
+	}
+	if _, err := NewClockJumpDetector(time.Second, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	wall := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var mono time.Duration
+	d := newClockJumpDetector(time.Second, time.Second*10,
+		func() time.Time { return wall },
+		func() time.Duration { return mono })
+	var events []ClockJump
+	d.Subscribe(func(j ClockJump) { events = append(events, j) })
+
+	advance := func(wallBy, monoBy time.Duration) {
+		wall = wall.Add(wallBy)
+		mono += monoBy
+	}
+
+	// Both clocks moving together, including a late tick, is not a jump
+	advance(time.Second, time.Second)
+	advance(time.Second*30, time.Second*30)
+	if d.check() {
+		t.Fatal("expected no jump when clocks agree")
+	}
+
+	// A suspend advances the wall clock only
+	advance(time.Hour, time.Second)
+	if !d.check() {
+		t.Fatal("expected jump after suspend")
+	}
+	// Small drift within the threshold is ignored
+	advance(time.Second*5, time.Second)
+	if d.check() {
+		t.Fatal("expected drift within threshold to be ignored")
+	}
+	// The clock being set backwards is also a jump
+	advance(-time.Minute, time.Second)
+	if !d.check() {
+		t.Fatal("expected jump after clock set backwards")
+	}
+
+	if d.Jumps() != 2 || len(events) != 2 {
+		t.Fatalf("received: %d jumps %d events but expected: 2", d.Jumps(), len(events))
+	}
+	if events[0].Jump != time.Hour-time.Second {
+		t.Errorf("received: %v but expected: %v", events[0].Jump, time.Hour-time.Second)
+	}
+	if events[1].Jump != -time.Minute-time.Second {
+		t.Errorf("received: %v but expected: %v", events[1].Jump, -time.Minute-time.Second)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	t.Parallel()
+	errA, errB, errC := errors.New("a"), errors.New("b"), errors.New("c")
+
+	if collected := CollectErrors(); len(collected) != 0 {
+		t.Fatalf("received: %v but expected empty", collected)
+	}
+	if collected := CollectErrors(nil, nil); len(collected) != 0 {
+		t.Fatalf("received: %v but expected empty", collected)
+	}
+
+	collected := CollectErrors(errA, nil, Errors{errB, nil, Errors{errC}}, Errors{})
+	if len(collected) != 3 || collected[0] != errA || collected[1] != errB || collected[2] != errC {
+		t.Fatalf("received: %v but expected: %v", collected, Errors{errA, errB, errC})
+	}
+	if collected.Error() != "a, b, c" {
+		t.Errorf("received: %q but expected: %q", collected.Error(), "a, b, c")
+	}
+}
+
+func TestErrorsError(t *testing.T) {
+	t.Parallel()
+	if s := (Errors{nil, errors.New("a"), nil, errors.New("b")}).Error(); s != "a, b" {
+		t.Errorf("received: %q but expected: %q", s, "a, b")
+	}
+	if s := (Errors{nil}).Error(); s != "" {
+		t.Errorf("received: %q but expected empty string", s)
+	}
+}
+
+func TestErrorsErrorOrNil(t *testing.T) {
+	t.Parallel()
+	if err := (Errors{}).ErrorOrNil(); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	if err := (Errors{nil, Errors{nil}}).ErrorOrNil(); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	errA := errors.New("a")
+	err := (Errors{nil, errA}).ErrorOrNil()
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0] != errA {
+		t.Fatalf("received: %v but expected: %v", err, Errors{errA})
+	}
+}
+
+func TestExtractHostPort(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		address string
+		host    string
+		port    int
+	}{
+		{"localhost:1337", "localhost", 1337},
+		{"api.example.com:443", "api.example.com", 443},
+		{"api.example.com", "api.example.com", 80},
+		{":8080", "localhost", 8080},
+		{"", "localhost", 80},
+		{"127.0.0.1:9050", "127.0.0.1", 9050},
+		{"[::1]:8080", "::1", 8080},
+		{"[2001:db8::1]:9050", "2001:db8::1", 9050},
+		{"[::1]", "::1", 80},
+		{"::1", "::1", 80},
+		{"fe80::1%eth0", "fe80::1%eth0", 80},
+	} {
+		if host := ExtractHost(tc.address); host != tc.host {
+			t.Errorf("%q host received: %q but expected: %q", tc.address, host, tc.host)
+		}
+		if port := ExtractPort(tc.address); port != tc.port {
+			t.Errorf("%q port received: %d but expected: %d", tc.address, port, tc.port)
+		}
+	}
+}
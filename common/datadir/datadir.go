@@ -0,0 +1,183 @@
+package datadir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zhiwei-w-luo/gotradebot/common"
+	"github.com/zhiwei-w-luo/gotradebot/common/file"
+	"github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+// Subdirectories of the data directory
+const (
+	LogsDir    = "logs"
+	TLSDir     = "tls"
+	ScriptsDir = "scripts"
+	HistoryDir = "history"
+	CrashesDir = "crashes"
+	ExportsDir = "exports"
+)
+
+var (
+	errEmptyRoot = errors.New("data directory root is empty")
+
+	subDirs = []string{LogsDir, TLSDir, ScriptsDir, HistoryDir, CrashesDir, ExportsDir}
+
+	// legacyRules map files the bot wrote directly into the root of a flat
+	// data directory to their subdirectory, the first matching pattern wins.
+	// Patterns only match names the bot is known to use, such as the default
+	// log file and its rotations, so files a user keeps in the data directory
+	// are left alone. Exports have no fixed name and are not migrated
+	legacyRules = []legacyRule{
+		{pattern: "log.txt", dir: LogsDir},
+		{pattern: "[0-9]*-log.txt", dir: LogsDir},
+		{pattern: "cert.pem", dir: TLSDir},
+		{pattern: "key.pem", dir: TLSDir},
+		{pattern: "*" + common.GctExt, dir: ScriptsDir},
+		{pattern: "crash-*", dir: CrashesDir},
+		{pattern: "*.crash", dir: CrashesDir},
+		{pattern: "*.history", dir: HistoryDir},
+	}
+)
+
+type legacyRule struct {
+	pattern string
+	dir     string
+}
+
+// Layout resolves the paths of the structured data directory
+type Layout struct {
+	root string
+}
+
+// Move is a planned relocation of a legacy file
+type Move struct {
+	From string
+	To   string
+}
+
+// New returns the layout rooted at root
+func New(root string) (*Layout, error) {
+	if root == "" {
+		return nil, errEmptyRoot
+	}
+	return &Layout{root: filepath.Clean(root)}, nil
+}
+
+// Root returns the data directory
+func (l *Layout) Root() string {
+	return l.root
+}
+
+// Logs returns the directory log files are written to
+func (l *Layout) Logs() string {
+	return filepath.Join(l.root, LogsDir)
+}
+
+// TLS returns the directory holding the TLS certificate and key
+func (l *Layout) TLS() string {
+	return filepath.Join(l.root, TLSDir)
+}
+
+// Scripts returns the directory holding script files
+func (l *Layout) Scripts() string {
+	return filepath.Join(l.root, ScriptsDir)
+}
+
+// History returns the directory holding historic data
+func (l *Layout) History() string {
+	return filepath.Join(l.root, HistoryDir)
+}
+
+// Crashes returns the directory crash reports are written to
+func (l *Layout) Crashes() string {
+	return filepath.Join(l.root, CrashesDir)
+}
+
+// Exports returns the directory exported data is written to
+func (l *Layout) Exports() string {
+	return filepath.Join(l.root, ExportsDir)
+}
+
+// Ensure creates any missing subdirectories and enforces their permissions
+func (l *Layout) Ensure() error {
+	for x := range subDirs {
+		dir := filepath.Join(l.root, subDirs[x])
+		if err := common.CreateDir(dir); err != nil {
+			return err
+		}
+		if err := common.ChangePermission(dir); err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// PlanMigration returns the moves required to bring files left in the root
+// by the legacy flat layout into their subdirectories. Files whose target
+// already exists are skipped so the migration never overwrites data
+func (l *Layout) PlanMigration() ([]Move, error) {
+	entries, err := os.ReadDir(l.root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var moves []Move
+	for x := range entries {
+		if !entries[x].Type().IsRegular() {
+			continue
+		}
+		name := entries[x].Name()
+		dir := legacyDir(name)
+		if dir == "" {
+			continue
+		}
+		target := filepath.Join(l.root, dir, name)
+		if file.Exists(target) {
+			log.Warnf(log.Global, "Data directory migration skipping %s, %s already exists\n", name, target)
+			continue
+		}
+		moves = append(moves, Move{From: filepath.Join(l.root, name), To: target})
+	}
+	return moves, nil
+}
+
+// Migrate moves legacy files into the structured layout and ensures every
+// subdirectory exists with the correct permissions. When dryRun is set the
+// planned moves are only logged. It is safe to run on every startup
+func (l *Layout) Migrate(dryRun bool) ([]Move, error) {
+	moves, err := l.PlanMigration()
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		for x := range moves {
+			log.Infof(log.Global, "Data directory migration dry run, would move %s to %s\n", moves[x].From, moves[x].To)
+		}
+		return moves, nil
+	}
+	for x := range moves {
+		if err = file.Move(moves[x].From, moves[x].To); err != nil {
+			return moves[:x], fmt.Errorf("moving %s: %w", moves[x].From, err)
+		}
+		log.Infof(log.Global, "Data directory migration moved %s to %s\n", moves[x].From, moves[x].To)
+	}
+	// Permissions are enforced after moving so relocated files are covered
+	return moves, l.Ensure()
+}
+
+// legacyDir returns the subdirectory a root level file belongs in, or an
+// empty string if it stays in the root
+func legacyDir(name string) string {
+	for x := range legacyRules {
+		if matched, _ := filepath.Match(legacyRules[x].pattern, name); matched {
+			return legacyRules[x].dir
+		}
+	}
+	return ""
+}
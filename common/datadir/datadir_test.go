@@ -0,0 +1,155 @@
+package datadir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	if _, err := New(""); !errors.Is(err, errEmptyRoot) {
+		t.Fatalf("received: %v but expected: %v", err, errEmptyRoot)
+	}
+
+	root := filepath.Join("data", "dir")
+	l, err := New(root + string(filepath.Separator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessors := map[string]string{
+		l.Root():    root,
+		l.Logs():    filepath.Join(root, LogsDir),
+		l.TLS():     filepath.Join(root, TLSDir),
+		l.Scripts(): filepath.Join(root, ScriptsDir),
+		l.History(): filepath.Join(root, HistoryDir),
+		l.Crashes(): filepath.Join(root, CrashesDir),
+		l.Exports(): filepath.Join(root, ExportsDir),
+	}
+	for received, expected := range accessors {
+		if received != expected {
+			t.Errorf("received: %s but expected: %s", received, expected)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	legacy := map[string]string{
+		"log.txt":            LogsDir,
+		"1610000000-log.txt": LogsDir,
+		"cert.pem":           TLSDir,
+		"key.pem":            TLSDir,
+		"strategy.gct":       ScriptsDir,
+		"crash-20210101.txt": CrashesDir,
+		"btcusd.history":     HistoryDir,
+	}
+	for name := range legacy {
+		writeTestFile(t, filepath.Join(root, name), name)
+	}
+	// Files the bot is not known to write stay in the root
+	unrelated := []string{"config.json", "trades.csv", "debug.log", "changelog.txt"}
+	for x := range unrelated {
+		writeTestFile(t, filepath.Join(root, unrelated[x]), unrelated[x])
+	}
+	// An existing target must never be overwritten
+	writeTestFile(t, filepath.Join(root, "other.gct"), "legacy")
+	writeTestFile(t, filepath.Join(root, ScriptsDir, "other.gct"), "current")
+
+	l, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moves, err := l.Migrate(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != len(legacy) {
+		t.Fatalf("received: %d planned moves but expected: %d", len(moves), len(legacy))
+	}
+	for name := range legacy {
+		if _, err = os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatalf("dry run moved %s: %v", name, err)
+		}
+	}
+
+	moves, err = l.Migrate(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != len(legacy) {
+		t.Fatalf("received: %d moves but expected: %d", len(moves), len(legacy))
+	}
+	for name, dir := range legacy {
+		if _, err = os.Stat(filepath.Join(root, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s to be moved out of the root, received: %v", name, err)
+		}
+		if data := readTestFile(t, filepath.Join(root, dir, name)); data != name {
+			t.Errorf("received: %s but expected: %s", data, name)
+		}
+	}
+	if data := readTestFile(t, filepath.Join(root, ScriptsDir, "other.gct")); data != "current" {
+		t.Errorf("received: %s but expected: %s", data, "current")
+	}
+	for x := range unrelated {
+		readTestFile(t, filepath.Join(root, unrelated[x]))
+	}
+	readTestFile(t, filepath.Join(root, "other.gct"))
+
+	for _, dir := range []string{l.Logs(), l.TLS(), l.Scripts(), l.History(), l.Crashes(), l.Exports()} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0770 {
+			t.Errorf("%s received: %v but expected: %v", dir, info.Mode().Perm(), os.FileMode(0770))
+		}
+	}
+
+	moves, err = l.Migrate(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 0 {
+		t.Errorf("received: %d moves on second run but expected: 0", len(moves))
+	}
+}
+
+func TestMigrateMissingRoot(t *testing.T) {
+	t.Parallel()
+	l, err := New(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves, err := l.Migrate(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 0 {
+		t.Errorf("received: %d moves but expected: 0", len(moves))
+	}
+}
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -24,6 +24,41 @@ func Write(file string, data []byte) error {
 	return ioutil.WriteFile(file, data, 0770)
 }
 
+// WriteAtomic writes data to a temporary file alongside the target and renames
+// it into place once synced, so the target is either the old or the new
+// content and never partially written. Permissions match Write
+func WriteAtomic(file string, data []byte) (err error) {
+	basePath := filepath.Dir(file)
+	if !Exists(basePath) {
+		if err = os.MkdirAll(basePath, 0770); err != nil {
+			return err
+		}
+	}
+	tmp, err := ioutil.TempFile(basePath, filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(0770); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
 // Writer creates a writer to a file or returns an error if it fails. This
 // func also ensures that all files are set to this permission (only rw access
 // for the running user and the group the user is a member of)
@@ -37,6 +72,20 @@ func Writer(file string) (*os.File, error) {
 	return os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0770)
 }
 
+// WriterWithPerm creates a writer to a file like Writer but sets the file to
+// the supplied permissions, including when the file already exists
+func WriterWithPerm(file string, perm os.FileMode) (*os.File, error) {
+	f, err := Writer(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Chmod(perm); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
 // Move moves a file from a source path to a destination path
 // This must be used across the codebase for compatibility with Docker volumes
 // and Golang (fixes Invalid cross-device link when using os.Rename)
@@ -93,6 +142,18 @@ func Exists(name string) bool {
 	return !os.IsNotExist(err)
 }
 
+// IsWritable returns whether an existing file can be opened for writing, it
+// is false for a missing file, a directory or a read only mount. The file is
+// not modified
+func IsWritable(name string) bool {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
 // WriteAsCSV takes a table of records and writes it as CSV
 func WriteAsCSV(filename string, records [][]string) error {
 	if len(records) == 0 {
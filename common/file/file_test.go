@@ -135,6 +135,31 @@ func TestExists(t *testing.T) {
 	}
 }
 
+func TestIsWritable(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if IsWritable(filepath.Join(dir, "missing.json")) {
+		t.Error("missing file should not be writable")
+	}
+	if IsWritable(dir) {
+		t.Error("directory should not be writable")
+	}
+	tmpFile := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(tmpFile, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !IsWritable(tmpFile) {
+		t.Error("file should be writable")
+	}
+	data, err := ioutil.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{}" {
+		t.Errorf("received: %s but expected: %s", data, "{}")
+	}
+}
+
 func TestWriteAsCSV(t *testing.T) {
 	tester := func(in string, data [][]string) error {
 		err := WriteAsCSV(in, data)
@@ -283,3 +308,83 @@ func TestWriterNoPermissionFails(t *testing.T) {
 		t.Error("Expected to fail when no permissions, but writer succeeded")
 	}
 }
+
+func TestWriterWithPerm(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("Skip file permissions")
+	}
+	tmp, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	target := filepath.Join(tmp, "config.json")
+	if err = ioutil.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := WriterWithPerm(target, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("received: %v but expected: %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+	if _, err = WriterWithPerm("", 0600); err == nil {
+		t.Error("expected error for empty path")
+	}
+}
+
+func TestWriteAtomic(t *testing.T) {
+	t.Parallel()
+	tmp, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	target := filepath.Join(tmp, "sub", "config.dat")
+	if err = WriteAtomic(target, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteAtomic(target, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("received: %s but expected: %s", data, "second")
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Dir(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("received: %d files but expected: 1, temporary file left behind", len(entries))
+	}
+
+	if err = WriteAtomic(filepath.Dir(target), []byte("dir")); err == nil {
+		t.Error("expected error when renaming over a directory")
+	}
+	entries, err = ioutil.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("received: %d entries but expected: 1, temporary file left behind", len(entries))
+	}
+}
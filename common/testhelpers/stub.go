@@ -0,0 +1,95 @@
+// Package testhelpers provides shared helpers for tests which need to
+// simulate exchange and other HTTP endpoints
+package testhelpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Response is a canned response served by a StubServer
+type Response struct {
+	// Status defaults to http.StatusOK
+	Status  int
+	Headers map[string]string
+	Body    []byte
+	// Latency delays the response, it is cut short if the client goes away
+	Latency time.Duration
+}
+
+// StubServer is an httptest server which serves programmed responses per
+// path. Each request to a path consumes the next response in its sequence
+// and the last response repeats once the sequence is exhausted, so a
+// failure then success sequence is Handle(path, failure, success). Paths
+// without responses return 404
+type StubServer struct {
+	*httptest.Server
+
+	mtx       sync.Mutex
+	responses map[string][]Response
+	hits      map[string]int
+}
+
+// NewStubServer starts a StubServer, it must be closed by the caller
+func NewStubServer() *StubServer {
+	s := &StubServer{
+		responses: make(map[string][]Response),
+		hits:      make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Handle replaces the responses for a path and resets its sequence, it may
+// be called while the server is in use to change the simulated behaviour
+func (s *StubServer) Handle(path string, responses ...Response) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.responses[path] = responses
+	s.hits[path] = 0
+}
+
+// Hits returns the number of requests received for a path since it was
+// last programmed
+func (s *StubServer) Hits(path string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.hits[path]
+}
+
+func (s *StubServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	responses := s.responses[r.URL.Path]
+	if len(responses) == 0 {
+		s.mtx.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	i := s.hits[r.URL.Path]
+	s.hits[r.URL.Path]++
+	s.mtx.Unlock()
+	if i >= len(responses) {
+		i = len(responses) - 1
+	}
+	resp := responses[i]
+
+	if resp.Latency > 0 {
+		timer := time.NewTimer(resp.Latency)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	if resp.Status == 0 {
+		resp.Status = http.StatusOK
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body) // nolint:errcheck // client disconnects are not of interest to tests
+}
@@ -0,0 +1,89 @@
+package testhelpers
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string) (status int, contentType, body string) {
+	t.Helper()
+	resp, err := http.Get(url) // nolint:gosec // test server URL
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(b)
+}
+
+func TestStubServerSequence(t *testing.T) {
+	t.Parallel()
+	s := NewStubServer()
+	defer s.Close()
+	s.Handle("/ping",
+		Response{Status: http.StatusServiceUnavailable},
+		Response{Headers: map[string]string{"Content-Type": "application/json"}, Body: []byte(`{}`)})
+
+	for i, exp := range []struct {
+		status      int
+		contentType string
+		body        string
+	}{
+		{http.StatusServiceUnavailable, "", ""},
+		{http.StatusOK, "application/json", "{}"},
+		{http.StatusOK, "application/json", "{}"},
+	} {
+		status, contentType, body := get(t, s.URL+"/ping")
+		if status != exp.status || contentType != exp.contentType || body != exp.body {
+			t.Errorf("request %d received: %d %q %q but expected: %d %q %q",
+				i, status, contentType, body, exp.status, exp.contentType, exp.body)
+		}
+	}
+	if hits := s.Hits("/ping"); hits != 3 {
+		t.Errorf("received: %d but expected: %d", hits, 3)
+	}
+
+	if status, _, _ := get(t, s.URL+"/unknown"); status != http.StatusNotFound {
+		t.Errorf("received: %d but expected: %d", status, http.StatusNotFound)
+	}
+
+	s.Handle("/ping", Response{Status: http.StatusTeapot})
+	if hits := s.Hits("/ping"); hits != 0 {
+		t.Errorf("received: %d but expected: %d", hits, 0)
+	}
+	if status, _, _ := get(t, s.URL+"/ping"); status != http.StatusTeapot {
+		t.Errorf("received: %d but expected: %d", status, http.StatusTeapot)
+	}
+}
+
+func TestStubServerLatency(t *testing.T) {
+	t.Parallel()
+	s := NewStubServer()
+	defer s.Close()
+	s.Handle("/slow", Response{Latency: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/slow", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("received: %v but expected: %v", err, context.DeadlineExceeded)
+	}
+	if time.Since(start) > time.Second*5 {
+		t.Error("expected latency to be cut short by the client deadline")
+	}
+}
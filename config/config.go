@@ -8,12 +8,16 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/zhiwei-w-luo/gotradebot/common"
+	"github.com/zhiwei-w-luo/gotradebot/common/file"
 	"github.com/zhiwei-w-luo/gotradebot/database"
 	"github.com/zhiwei-w-luo/gotradebot/log"
 )
@@ -23,12 +27,15 @@ const (
 	FXProviderFixer                      = "fixer"
 	EncryptedFile                        = "config.dat"
 	File                                 = "config.json"
+	StateFile                            = "state.json"
 	TestFile                             = "../testdata/configtest.json"
 	fileEncryptionPrompt                 = 0
 	fileEncryptionEnabled                = 1
 	fileEncryptionDisabled               = -1
+	restrictedFilePerm                   = 0600
 	pairsLastUpdatedWarningThreshold     = 30 // 30 days
 	defaultHTTPTimeout                   = time.Second * 15
+	minSuggestedHTTPTimeout              = time.Second
 	defaultWebsocketResponseCheckTimeout = time.Millisecond * 30
 	defaultWebsocketResponseMaxLimit     = time.Second * 7
 	defaultWebsocketOrderbookBufferLimit = 5
@@ -64,6 +71,8 @@ const (
 	DefaultUnsetAPISecret                = "Secret"
 	DefaultUnsetAccountPlan              = "accountPlan"
 	DefaultForexProviderExchangeRatesAPI = "ExchangeRateHost"
+	// RedactedValue replaces secrets in redacted configs
+	RedactedValue = log.RedactedValue
 )
 
 // Variables here are used for configuration
@@ -71,26 +80,46 @@ var (
 	Cfg                 Config
 	m                   sync.Mutex
 	ErrExchangeNotFound = errors.New("exchange not found")
+
+	errNegativeHTTPTimeout = errors.New("global HTTP timeout cannot be negative")
 )
 
 // Config is the overarching object that holds all the information for
 // prestart management of Portfolio, Webserver and Enabled Exchanges
 type Config struct {
-	Name                 string                    `json:"name"`
-	DataDirectory        string                    `json:"dataDirectory"`
-	EncryptConfig        int                       `json:"encryptConfig"`
-	GlobalHTTPTimeout    time.Duration             `json:"globalHTTPTimeout"`
-	Database             database.Config           `json:"database"`
-	Logging              log.Config                `json:"logging"`
+	Name              string          `json:"name"`
+	DataDirectory     string          `json:"dataDirectory"`
+	EncryptConfig     int             `json:"encryptConfig"`
+	GlobalHTTPTimeout time.Duration   `json:"globalHTTPTimeout"`
+	Database          database.Config `json:"database"`
+	Logging           log.Config      `json:"logging"`
+
+	// RestrictFilePermissions saves the config readable and writable by the
+	// owner only, intended for users who opt out of encryption
+	RestrictFilePermissions bool `json:"restrictFilePermissions,omitempty"`
+
+	// ExchangeHTTPProxies maps exchange names to the proxy URL their HTTP
+	// requests are sent through, for exchanges only reachable from certain
+	// regions. Exchanges not listed use the global client
+	ExchangeHTTPProxies map[string]string `json:"exchangeHTTPProxies,omitempty"`
 
 	// encryption session values
 	storedSalt []byte
 	sessionDK  []byte
-
-
+	// keyPrompter supplies the encryption key, stdin when nil
+	keyPrompter KeyPrompter
+	// loadedKDF is the key derivation detected when an encrypted config
+	// was decrypted, empty for a plaintext config
+	loadedKDF string
+	// readOnly redirects writes from the config file to the state file
+	readOnly bool
+	// readOnlyNoted is set once the redirect to the state file is logged
+	readOnlyNoted bool
+	// fileLevels are the sub logger levels read from the config file, keyed
+	// by upper cased name, only levels which differ are saved as state
+	fileLevels map[string]string
 }
 
-
 // LoadConfig loads your configuration file into your configuration object
 func (c *Config) LoadConfig(configPath string, dryrun bool) error {
 	err := c.ReadConfigFromFile(configPath, dryrun)
@@ -110,6 +139,7 @@ func (c *Config) UpdateConfig(configPath string, newCfg *Config, dryrun bool) er
 
 	c.Name = newCfg.Name
 	c.EncryptConfig = newCfg.EncryptConfig
+	c.RestrictFilePermissions = newCfg.RestrictFilePermissions
 	c.Currency = newCfg.Currency
 	c.GlobalHTTPTimeout = newCfg.GlobalHTTPTimeout
 	c.Portfolio = newCfg.Portfolio
@@ -127,6 +157,49 @@ func (c *Config) UpdateConfig(configPath string, newCfg *Config, dryrun bool) er
 	return c.LoadConfig(configPath, dryrun)
 }
 
+// CheckGlobalHTTPTimeout validates the global HTTP timeout, a zero value
+// leaves the common HTTP client default in place and values under a second
+// are allowed but warned about as they are likely a units mistake
+func (c *Config) CheckGlobalHTTPTimeout() error {
+	if c.GlobalHTTPTimeout < 0 {
+		return fmt.Errorf("%w: %s", errNegativeHTTPTimeout, c.GlobalHTTPTimeout)
+	}
+	if c.GlobalHTTPTimeout > 0 && c.GlobalHTTPTimeout < minSuggestedHTTPTimeout {
+		log.Warnf(log.ConfigMgr, "Global HTTP timeout %s is below %s, requests are likely to time out\n",
+			c.GlobalHTTPTimeout, minSuggestedHTTPTimeout)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the config safe to display or attach to a bug
+// report, secrets are replaced with RedactedValue and the encryption session
+// key is dropped. Pointer fields other than the log file settings are shared
+// with c and must not be modified through the copy
+func (c *Config) Redacted() *Config {
+	cpy := *c
+	cpy.sessionDK, cpy.storedSalt = nil, nil
+	if cpy.Database.Password != "" {
+		cpy.Database.Password = RedactedValue
+	}
+	if c.ExchangeHTTPProxies != nil {
+		// Proxy URLs may hold credentials in their user info
+		cpy.ExchangeHTTPProxies = make(map[string]string, len(c.ExchangeHTTPProxies))
+		for name, proxy := range c.ExchangeHTTPProxies {
+			if u, err := url.Parse(proxy); err == nil && u.User != nil {
+				u.User = url.User(RedactedValue)
+				proxy = u.String()
+			}
+			cpy.ExchangeHTTPProxies[name] = proxy
+		}
+	}
+	cpy.Logging.SubLoggers = append([]log.SubLoggerConfig(nil), c.Logging.SubLoggers...)
+	if c.Logging.LoggerFileConfig != nil {
+		fileConfig := *c.Logging.LoggerFileConfig
+		cpy.Logging.LoggerFileConfig = &fileConfig
+	}
+	return &cpy
+}
+
 // GetConfig returns a pointer to a configuration object
 func GetConfig() *Config {
 	return &Cfg
@@ -146,14 +219,28 @@ func (c *Config) ReadConfigFromFile(configPath string, dryrun bool) error {
 		return err
 	}
 	defer confFile.Close()
-	result, wasEncrypted, err := ReadConfig(confFile, func() ([]byte, error) { return PromptForConfigKey(false) })
+	prompter := c.getKeyPrompter()
+	result, wasEncrypted, err := ReadConfig(confFile, func() ([]byte, error) { return prompter.PromptForKey(false) })
 	if err != nil {
 		return fmt.Errorf("error reading config %w", err)
 	}
 	// Override values in the current config
+	readOnly := c.readOnly
 	*c = *result
+	c.keyPrompter = prompter
+	c.readOnly = readOnly || !file.IsWritable(defaultPath)
+	if c.readOnly {
+		c.noteReadOnly(defaultPath)
+	}
+	if err = c.loadState(); err != nil {
+		return fmt.Errorf("error reading state file %w", err)
+	}
 
-	if dryrun || wasEncrypted || c.EncryptConfig == fileEncryptionDisabled {
+	if c.RestrictFilePermissions && !wasEncrypted {
+		warnOpenFilePermissions(confFile)
+	}
+
+	if dryrun || c.readOnly || wasEncrypted || c.EncryptConfig == fileEncryptionDisabled {
 		return nil
 	}
 
@@ -177,6 +264,115 @@ func (c *Config) ReadConfigFromFile(configPath string, dryrun bool) error {
 	return nil
 }
 
+// SetReadOnly marks the config as read only, as when the file is mounted
+// read only in a container. A read only config is never written back, its
+// runtime changes are saved to the state file instead. This is also set by
+// ReadConfigFromFile when the file cannot be opened for writing
+func (c *Config) SetReadOnly(readOnly bool) {
+	c.readOnly = readOnly
+}
+
+// IsReadOnly returns whether writes go to the state file rather than the
+// config file
+func (c *Config) IsReadOnly() bool {
+	return c.readOnly
+}
+
+// StatePath returns the path of the state file in the data directory
+func (c *Config) StatePath() string {
+	dir := c.DataDirectory
+	if dir == "" {
+		dir = common.GetDefaultDataDir(runtime.GOOS)
+	}
+	return filepath.Join(dir, StateFile)
+}
+
+// noteReadOnly logs once that changes to a read only config are saved to
+// the state file
+func (c *Config) noteReadOnly(configPath string) {
+	if c.readOnlyNoted {
+		return
+	}
+	c.readOnlyNoted = true
+	log.Infof(log.ConfigMgr, "Config file %s is read only, runtime changes will be saved to %s\n", configPath, c.StatePath())
+}
+
+// loadState merges the sub logger levels saved in the state file, if there
+// is one, onto the sub loggers from the config file by name. Sub loggers
+// missing from the config file are added using the global output
+func (c *Config) loadState() error {
+	c.fileLevels = make(map[string]string, len(c.Logging.SubLoggers))
+	for x := range c.Logging.SubLoggers {
+		c.fileLevels[strings.ToUpper(c.Logging.SubLoggers[x].Name)] = c.Logging.SubLoggers[x].Level
+	}
+	data, err := ioutil.ReadFile(c.StatePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s state
+	if err = json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for name, level := range s.SubLoggerLevels {
+		found := false
+		for x := range c.Logging.SubLoggers {
+			if strings.EqualFold(c.Logging.SubLoggers[x].Name, name) {
+				c.Logging.SubLoggers[x].Level = level
+				found = true
+			}
+		}
+		if !found {
+			c.Logging.SubLoggers = append(c.Logging.SubLoggers, log.SubLoggerConfig{
+				Name:   name,
+				Level:  level,
+				Output: c.Logging.Output,
+			})
+		}
+	}
+	return nil
+}
+
+// saveState writes the sub logger levels which differ from the config file
+// to the state file
+func (c *Config) saveState() error {
+	var s state
+	for x := range c.Logging.SubLoggers {
+		name := strings.ToUpper(c.Logging.SubLoggers[x].Name)
+		if level, ok := c.fileLevels[name]; ok && level == c.Logging.SubLoggers[x].Level {
+			continue
+		}
+		if s.SubLoggerLevels == nil {
+			s.SubLoggerLevels = make(map[string]string)
+		}
+		s.SubLoggerLevels[name] = c.Logging.SubLoggers[x].Level
+	}
+	payload, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return err
+	}
+	return file.WriteAtomic(c.StatePath(), payload)
+}
+
+// warnOpenFilePermissions warns when a config expecting restricted
+// permissions can be accessed by users other than its owner
+func warnOpenFilePermissions(f *os.File) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		log.Errorf(log.ConfigMgr, "Cannot check config file permissions. Error: %s\n", err)
+		return
+	}
+	if perm := info.Mode().Perm(); perm&^restrictedFilePerm != 0 {
+		log.Warnf(log.ConfigMgr, "Config file %s has permissions %v but restrictFilePermissions is set, it will be restricted to %v on next save\n",
+			f.Name(), perm, os.FileMode(restrictedFilePerm))
+	}
+}
+
 // ReadConfig verifies and checks for encryption and loads the config from a JSON object.
 // Prompts for decryption key, if target data is encrypted.
 // Returns the loaded configuration and whether it was encrypted.
@@ -209,8 +405,9 @@ func readEncryptedConfWithKey(reader *bufio.Reader, keyProvider func() ([]byte,
 	for errCounter := 0; errCounter < maxAuthFailures; errCounter++ {
 		key, err := keyProvider()
 		if err != nil {
-			log.Errorf(log.ConfigMgr, "PromptForConfigKey err: %s", err)
-			continue
+			// A failed prompt is not a wrong key, retrying would fail the
+			// same way
+			return nil, fmt.Errorf("config key prompt: %w", err)
 		}
 
 		var c *Config
@@ -237,14 +434,23 @@ func readEncryptedConf(reader io.Reader, key []byte) (*Config, error) {
 
 // SaveConfigToFile saves your configuration to your desired path as a JSON object.
 // The function encrypts the data and prompts for encryption key, if necessary
+// A read only config saves its runtime changes to the state file instead
 func (c *Config) SaveConfigToFile(configPath string) error {
 	defaultPath, _, err := GetFilePath(configPath)
 	if err != nil {
 		return err
 	}
+	if c.readOnly {
+		c.noteReadOnly(defaultPath)
+		return c.saveState()
+	}
 	var writer *os.File
 	provider := func() (io.Writer, error) {
-		writer, err = file.Writer(defaultPath)
+		if c.RestrictFilePermissions {
+			writer, err = file.WriterWithPerm(defaultPath, restrictedFilePerm)
+		} else {
+			writer, err = file.Writer(defaultPath)
+		}
 		return writer, err
 	}
 	defer func() {
@@ -255,7 +461,15 @@ func (c *Config) SaveConfigToFile(configPath string) error {
 			}
 		}
 	}()
-	return c.Save(provider, func() ([]byte, error) { return PromptForConfigKey(true) })
+	err = c.Save(provider, func() ([]byte, error) { return c.getKeyPrompter().PromptForKey(true) })
+	if err != nil {
+		return err
+	}
+	// The config file now holds any runtime changes from the state file
+	if err = os.Remove(c.StatePath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Errorf(log.ConfigMgr, "Cannot remove state file. Error: %s\n", err)
+	}
+	return nil
 }
 
 // Save saves your configuration to the writer as a JSON object
@@ -326,4 +540,4 @@ func GetFilePath(configFile string) (configPath string, isImplicitDefaultPath bo
 	}
 
 	return configFile, true, nil
-}
\ No newline at end of file
+}
@@ -13,6 +13,8 @@ import (
 
 	"github.com/zhiwei-w-luo/gotradebot/common"
 	"github.com/zhiwei-w-luo/gotradebot/common/crypto"
+	"github.com/zhiwei-w-luo/gotradebot/common/file"
+	gctlog "github.com/zhiwei-w-luo/gotradebot/log"
 	"golang.org/x/crypto/scrypt"
 )
 
@@ -26,6 +28,18 @@ const (
 	SaltRandomLength = 12
 
 	errAESBlockSize = "config file data is too small for the AES required block size"
+
+	// KDFScrypt is reported by EncryptionStatus for a salted config whose
+	// key is derived with scrypt, all configs saved by this version use it
+	KDFScrypt = "scrypt"
+	// KDFNone is reported by EncryptionStatus for a legacy config without a
+	// salt, where the key is used directly
+	KDFNone = "none"
+)
+
+var (
+	errBootstrapPathEmpty = errors.New("bootstrap config path is empty")
+	errBootstrapKeyEmpty  = errors.New("bootstrap encryption key is empty")
 )
 
 // promptForConfigEncryption asks for encryption confirmation
@@ -46,6 +60,67 @@ func Unencrypted() ([]byte, error) {
 	return nil, errors.New("encryption key was requested, no key provided")
 }
 
+// EncryptionInfo describes how the config is protected, it never holds key
+// material
+type EncryptionInfo struct {
+	Enabled bool `json:"enabled"`
+	// KDF is the key derivation of the loaded file, or of the next save
+	// when a session key is held, empty when neither applies
+	KDF            string `json:"kdf,omitempty"`
+	SessionKeyHeld bool   `json:"sessionKeyHeld"`
+}
+
+// EncryptionStatus returns whether config encryption is enabled, the key
+// derivation in use and whether a session key is held, so the next save
+// will not prompt for the key
+func (c *Config) EncryptionStatus() EncryptionInfo {
+	info := EncryptionInfo{
+		Enabled:        c.EncryptConfig == fileEncryptionEnabled,
+		KDF:            c.loadedKDF,
+		SessionKeyHeld: len(c.sessionDK) != 0,
+	}
+	if info.KDF == "" && info.SessionKeyHeld {
+		info.KDF = KDFScrypt
+	}
+	return info
+}
+
+// KeyPrompter supplies the config encryption key, initialSetup is true when
+// a new key is being chosen rather than an existing one entered
+type KeyPrompter interface {
+	PromptForKey(initialSetup bool) ([]byte, error)
+}
+
+// KeyPrompterFunc adapts a func to a KeyPrompter, for headless key sources
+// such as environment variables, files or test stubs
+type KeyPrompterFunc func(initialSetup bool) ([]byte, error)
+
+// PromptForKey calls f
+func (f KeyPrompterFunc) PromptForKey(initialSetup bool) ([]byte, error) {
+	return f(initialSetup)
+}
+
+// StdinKeyPrompter is the default KeyPrompter, asking for the key on stdin
+type StdinKeyPrompter struct{}
+
+// PromptForKey asks for the key on stdin via PromptForConfigKey
+func (StdinKeyPrompter) PromptForKey(initialSetup bool) ([]byte, error) {
+	return PromptForConfigKey(initialSetup)
+}
+
+// SetKeyPrompter sets the key source used when reading and saving an
+// encrypted config, a nil prompter restores the stdin default
+func (c *Config) SetKeyPrompter(p KeyPrompter) {
+	c.keyPrompter = p
+}
+
+func (c *Config) getKeyPrompter() KeyPrompter {
+	if c.keyPrompter == nil {
+		return StdinKeyPrompter{}
+	}
+	return c.keyPrompter
+}
+
 // PromptForConfigKey asks for configuration key
 // if initialSetup is true, the password needs to be repeated
 func PromptForConfigKey(initialSetup bool) ([]byte, error) {
@@ -85,6 +160,37 @@ func PromptForConfigKey(initialSetup bool) ([]byte, error) {
 	return cryptoKey, nil
 }
 
+// Bootstrap encrypts base with key and atomically writes it to path without
+// prompting, for automated provisioning. When base is nil a config holding
+// only default logging settings is written. base is left with encryption
+// enabled and the derived session key set, so it can be saved again
+func Bootstrap(path string, base *Config, key []byte) error {
+	if path == "" {
+		return errBootstrapPathEmpty
+	}
+	if len(key) == 0 {
+		return errBootstrapKeyEmpty
+	}
+	if base == nil {
+		base = &Config{Logging: *gctlog.GenDefaultSettings()}
+	}
+
+	sessionDK, storedSalt, err := makeNewSessionDK(key)
+	if err != nil {
+		return err
+	}
+	base.EncryptConfig = fileEncryptionEnabled
+	base.sessionDK, base.storedSalt = sessionDK, storedSalt
+
+	var payload bytes.Buffer
+	err = base.Save(func() (io.Writer, error) { return &payload, nil },
+		func() ([]byte, error) { return key, nil })
+	if err != nil {
+		return err
+	}
+	return file.WriteAtomic(path, payload.Bytes())
+}
+
 // EncryptConfigFile encrypts configuration data that is parsed in with a key
 // and returns it as a byte array with an error
 func EncryptConfigFile(configData, key []byte) ([]byte, error) {
@@ -142,6 +248,7 @@ func (c *Config) decryptConfigData(configReader io.Reader, key []byte) ([]byte,
 		return nil, err
 	}
 
+	kdf := KDFNone
 	if ConfirmSalt(configData) {
 		salt := make([]byte, len(SaltPrefix)+SaltRandomLength)
 		salt = configData[0:len(salt)]
@@ -152,6 +259,7 @@ func (c *Config) decryptConfigData(configReader io.Reader, key []byte) ([]byte,
 		}
 
 		configData = configData[len(salt):]
+		kdf = KDFScrypt
 	}
 
 	blockDecrypt, err := aes.NewCipher(key)
@@ -175,6 +283,7 @@ func (c *Config) decryptConfigData(configReader io.Reader, key []byte) ([]byte,
 		return nil, err
 	}
 	c.sessionDK, c.storedSalt = sessionDK, storedSalt
+	c.loadedKDF = kdf
 
 	return result, nil
 }
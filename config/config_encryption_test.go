@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+// staticKey returns a KeyPrompter which always supplies key
+func staticKey(key []byte) KeyPrompter {
+	return KeyPrompterFunc(func(bool) ([]byte, error) { return key, nil })
+}
+
+func TestBootstrap(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), EncryptedFile)
+	key := []byte("bootstrap-key")
+	if err := Bootstrap("", nil, key); !errors.Is(err, errBootstrapPathEmpty) {
+		t.Fatalf("received: %v but expected: %v", err, errBootstrapPathEmpty)
+	}
+	if err := Bootstrap(path, nil, nil); !errors.Is(err, errBootstrapKeyEmpty) {
+		t.Fatalf("received: %v but expected: %v", err, errBootstrapKeyEmpty)
+	}
+
+	base := &Config{
+		Name:          "bootstrap",
+		DataDirectory: t.TempDir(),
+		Logging:       *log.GenDefaultSettings(),
+	}
+	if err := Bootstrap(path, base, key); err != nil {
+		t.Fatal(err)
+	}
+	if !base.EncryptionStatus().Enabled {
+		t.Error("expected the base config to be left with encryption enabled")
+	}
+
+	loaded := &Config{}
+	loaded.SetKeyPrompter(staticKey(key))
+	if err := loaded.ReadConfigFromFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := json.Marshal(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, expected) {
+		t.Fatalf("received: %s but expected: %s", received, expected)
+	}
+
+	wrongKey := &Config{}
+	wrongKey.SetKeyPrompter(staticKey([]byte("wrong-key")))
+	if err = wrongKey.ReadConfigFromFile(path, true); err == nil {
+		t.Fatal("expected a wrong key to fail decryption")
+	}
+}
+
+func TestKeyPrompter(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), EncryptedFile)
+	key := []byte("prompter-key")
+	base := &Config{Name: "prompter", DataDirectory: t.TempDir(), Logging: *log.GenDefaultSettings()}
+	if err := Bootstrap(path, base, key); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	loaded := &Config{}
+	loaded.SetKeyPrompter(KeyPrompterFunc(func(initialSetup bool) ([]byte, error) {
+		calls++
+		if initialSetup {
+			t.Error("expected an existing key to be requested")
+		}
+		return key, nil
+	}))
+	if err := loaded.ReadConfigFromFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("received: %d prompts but expected: %d", calls, 1)
+	}
+	if loaded.Name != base.Name {
+		t.Fatalf("received: %s but expected: %s", loaded.Name, base.Name)
+	}
+
+	errPrompt := errors.New("no key available")
+	failing := &Config{}
+	failing.SetKeyPrompter(KeyPrompterFunc(func(bool) ([]byte, error) { return nil, errPrompt }))
+	if err := failing.ReadConfigFromFile(path, true); !errors.Is(err, errPrompt) {
+		t.Fatalf("received: %v but expected: %v", err, errPrompt)
+	}
+}
+
+func TestEncryptionStatus(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	key := []byte("status-key")
+	base := &Config{Name: "status", DataDirectory: t.TempDir(), Logging: *log.GenDefaultSettings()}
+
+	encrypted := filepath.Join(dir, EncryptedFile)
+	if err := Bootstrap(encrypted, base, key); err != nil {
+		t.Fatal(err)
+	}
+
+	base.EncryptConfig = fileEncryptionDisabled
+	payload, err := json.Marshal(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := filepath.Join(dir, File)
+	if err = ioutil.WriteFile(plaintext, payload, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy files have no salt and use the key directly
+	legacyKey := bytes.Repeat([]byte{1}, 32)
+	legacyData, err := (&Config{sessionDK: legacyKey}).encryptConfigFile(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := filepath.Join(dir, "legacy.dat")
+	if err = ioutil.WriteFile(legacy, legacyData, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		key      []byte
+		readErr  bool
+		expected EncryptionInfo
+	}{
+		{
+			name:     "encrypted",
+			path:     encrypted,
+			key:      key,
+			expected: EncryptionInfo{Enabled: true, KDF: KDFScrypt, SessionKeyHeld: true},
+		},
+		{
+			name:     "legacy unsalted",
+			path:     legacy,
+			key:      legacyKey,
+			expected: EncryptionInfo{KDF: KDFNone, SessionKeyHeld: true},
+		},
+		{
+			name:     "plaintext",
+			path:     plaintext,
+			expected: EncryptionInfo{},
+		},
+		{
+			name:     "missing",
+			path:     filepath.Join(dir, "missing.json"),
+			readErr:  true,
+			expected: EncryptionInfo{},
+		},
+		{
+			name:     "unreadable",
+			path:     dir,
+			readErr:  true,
+			expected: EncryptionInfo{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			c := &Config{}
+			c.SetKeyPrompter(staticKey(tc.key))
+			if err := c.ReadConfigFromFile(tc.path, true); (err != nil) != tc.readErr {
+				t.Fatalf("received: %v but expected a read error: %t", err, tc.readErr)
+			}
+			if status := c.EncryptionStatus(); status != tc.expected {
+				t.Fatalf("received: %+v but expected: %+v", status, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+// writeConfigFile writes c as a plaintext config file to path and returns
+// its contents
+func writeConfigFile(t *testing.T, path string, c *Config) []byte {
+	t.Helper()
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path, payload, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// subLoggerLevel returns the configured level of the named sub logger
+func subLoggerLevel(c *Config, name string) string {
+	for x := range c.Logging.SubLoggers {
+		if strings.EqualFold(c.Logging.SubLoggers[x].Name, name) {
+			return c.Logging.SubLoggers[x].Level
+		}
+	}
+	return ""
+}
+
+func TestStateOverlay(t *testing.T) {
+	t.Parallel()
+	dataDir := t.TempDir()
+	c := &Config{
+		Name:          "overlay",
+		DataDirectory: dataDir,
+		EncryptConfig: fileEncryptionDisabled,
+		Logging:       *log.GenDefaultSettings(),
+	}
+	c.Logging.SubLoggers = []log.SubLoggerConfig{{Name: "kept", Level: "INFO", Output: "console"}}
+	configPath := filepath.Join(t.TempDir(), File)
+	payload := writeConfigFile(t, configPath, c)
+
+	loaded := &Config{}
+	loaded.SetReadOnly(true)
+	if err := loaded.ReadConfigFromFile(configPath, false); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Logging.SubLoggers = append(loaded.Logging.SubLoggers,
+		log.SubLoggerConfig{Name: "overlaytest", Level: "ERROR", Output: "console"})
+	if err := loaded.SaveConfigToFile(configPath); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+
+	// Only the level changed at runtime is kept as state
+	data, err := ioutil.ReadFile(loaded.StatePath())
+	if err != nil {
+		t.Fatalf("expected the state file to be written: %v", err)
+	}
+	var s state
+	if err = json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.SubLoggerLevels) != 1 || s.SubLoggerLevels["OVERLAYTEST"] != "ERROR" {
+		t.Fatalf("received: %v but expected only the runtime level", s.SubLoggerLevels)
+	}
+
+	// The state file is overlaid when the config is next loaded
+	reloaded := &Config{}
+	reloaded.SetReadOnly(true)
+	if err = reloaded.ReadConfigFromFile(configPath, false); err != nil {
+		t.Fatal(err)
+	}
+	if level := subLoggerLevel(reloaded, "overlaytest"); level != "ERROR" {
+		t.Fatalf("received: %q but expected: %q", level, "ERROR")
+	}
+	if level := subLoggerLevel(reloaded, "kept"); level != "INFO" {
+		t.Fatalf("received: %q but expected: %q", level, "INFO")
+	}
+	onDisk, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(onDisk, payload) {
+		t.Fatal("expected a read only config file to be left unchanged")
+	}
+}
+
+func TestStateOverlayKeepsConfigEdits(t *testing.T) {
+	t.Parallel()
+	c := &Config{
+		Name:          "edits",
+		DataDirectory: t.TempDir(),
+		EncryptConfig: fileEncryptionDisabled,
+		Logging:       *log.GenDefaultSettings(),
+	}
+	c.Logging.SubLoggers = []log.SubLoggerConfig{{Name: "kept", Level: "INFO", Output: "console"}}
+	configPath := filepath.Join(t.TempDir(), File)
+	writeConfigFile(t, configPath, c)
+
+	loaded := &Config{}
+	loaded.SetReadOnly(true)
+	if err := loaded.ReadConfigFromFile(configPath, false); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Logging.SubLoggers = append(loaded.Logging.SubLoggers,
+		log.SubLoggerConfig{Name: "overlaytest", Level: "ERROR", Output: "console"})
+	if err := loaded.SaveConfigToFile(configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// An operator edit to the config file after the runtime change is kept,
+	// only the runtime level is merged on top
+	c.Logging.Output = "stdout"
+	c.Logging.SubLoggers[0].Level = "WARN"
+	writeConfigFile(t, configPath, c)
+	reloaded := &Config{}
+	reloaded.SetReadOnly(true)
+	if err := reloaded.ReadConfigFromFile(configPath, false); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Logging.Output != "stdout" {
+		t.Fatalf("received: %q but expected: %q", reloaded.Logging.Output, "stdout")
+	}
+	if level := subLoggerLevel(reloaded, "kept"); level != "WARN" {
+		t.Fatalf("received: %q but expected: %q", level, "WARN")
+	}
+	if level := subLoggerLevel(reloaded, "overlaytest"); level != "ERROR" {
+		t.Fatalf("received: %q but expected: %q", level, "ERROR")
+	}
+
+	// Saving to a writable config folds the state into it
+	reloaded.SetReadOnly(false)
+	if err := reloaded.SaveConfigToFile(configPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadFile(reloaded.StatePath()); err == nil {
+		t.Fatal("expected the state file to be removed")
+	}
+	reloaded = &Config{}
+	if err := reloaded.ReadConfigFromFile(configPath, false); err != nil {
+		t.Fatal(err)
+	}
+	if level := subLoggerLevel(reloaded, "overlaytest"); level != "ERROR" {
+		t.Fatalf("received: %q but expected the saved level: %q", level, "ERROR")
+	}
+}
@@ -2,11 +2,23 @@ package config
 
 import "time"
 
+// state holds the runtime changes a read only config saves to the state
+// file. Only sub logger levels are kept so that every other setting is
+// still taken from the config file
+type state struct {
+	// SubLoggerLevels maps upper cased sub logger names to the level set
+	// at runtime
+	SubLoggerLevels map[string]string `json:"subLoggerLevels,omitempty"`
+}
+
 // ConnectionMonitorConfig defines the connection monitor variables to ensure
 // that there is internet connectivity
 type ConnectionMonitorConfig struct {
 	DNSList          []string      `json:"preferredDNSList"`
 	PublicDomainList []string      `json:"preferredDomainList"`
 	CheckInterval    time.Duration `json:"checkInterval"`
+	// ExchangeHealthEndpoints maps exchange names to a REST endpoint, such
+	// as a ping or server time call, used to track each exchange's
+	// reachability separately from general connectivity
+	ExchangeHealthEndpoints map[string]string `json:"exchangeHealthEndpoints,omitempty"`
 }
-
@@ -2,9 +2,13 @@ package connchecker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zhiwei-w-luo/gotradebot/log"
@@ -14,6 +18,8 @@ import (
 // checking if the connection is lost
 const (
 	DefaultCheckInterval = time.Second
+	// ExchangeCheckTimeout is the timeout of each exchange health request
+	ExchangeCheckTimeout = time.Second * 5
 
 	ConnRe       = "Internet connectivity re-established"
 	ConnLost     = "Internet connectivity lost"
@@ -21,6 +27,8 @@ const (
 	ConnNotFound = "No internet connectivity"
 )
 
+var errExchangeUnhealthy = errors.New("exchange health check failed")
+
 // Default check lists
 var (
 	DefaultDNSList    = []string{"8.8.8.8", "8.8.4.4", "1.1.1.1", "1.0.0.1"}
@@ -75,6 +83,17 @@ type Checker struct {
 	wg            sync.WaitGroup
 	connected     bool
 	sync.Mutex
+
+	// exchanges holds the health endpoints and reachability of individual
+	// exchanges, keyed by lower case exchange name
+	exchanges        map[string]*exchangeHealth
+	onExchangeChange func(exchange string, online bool)
+	exchangeChecking int32
+}
+
+type exchangeHealth struct {
+	endpoint string
+	online   bool
 }
 
 // Shutdown cleanly shutsdown monitor routine
@@ -94,6 +113,7 @@ func (c *Checker) Monitor(wg *sync.WaitGroup) {
 		select {
 		case <-tick.C:
 			go c.connectionTest()
+			go c.exchangeTest()
 		case <-c.shutdown:
 			return
 		}
@@ -183,6 +203,103 @@ func (c *Checker) CheckHost(host string) error {
 	return err
 }
 
+// SetExchangeEndpoints replaces the exchange health endpoints, keyed by
+// exchange name, and checks them straight away. They are then checked on
+// every interval alongside general connectivity. onChange, if not nil, is
+// called whenever an exchange goes on or offline
+func (c *Checker) SetExchangeEndpoints(endpoints map[string]string, onChange func(exchange string, online bool)) {
+	exchanges := make(map[string]*exchangeHealth, len(endpoints))
+	for name, endpoint := range endpoints {
+		exchanges[strings.ToLower(name)] = &exchangeHealth{endpoint: endpoint}
+	}
+	c.Lock()
+	c.exchanges = exchanges
+	c.onExchangeChange = onChange
+	c.Unlock()
+	c.exchangeTest()
+}
+
+// exchangeTest checks every exchange health endpoint concurrently, skipping
+// the run if the previous one has not finished
+func (c *Checker) exchangeTest() {
+	if !atomic.CompareAndSwapInt32(&c.exchangeChecking, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&c.exchangeChecking, 0)
+
+	c.Lock()
+	endpoints := make(map[string]string, len(c.exchanges))
+	for name, health := range c.exchanges {
+		endpoints[name] = health.endpoint
+	}
+	c.Unlock()
+
+	var wg sync.WaitGroup
+	for name, endpoint := range endpoints {
+		wg.Add(1)
+		go func(name, endpoint string) {
+			defer wg.Done()
+			c.setExchangeOnline(name, c.CheckExchange(endpoint) == nil)
+		}(name, endpoint)
+	}
+	wg.Wait()
+}
+
+// setExchangeOnline records the reachability of an exchange, logging and
+// notifying on transitions
+func (c *Checker) setExchangeOnline(name string, online bool) {
+	c.Lock()
+	health, ok := c.exchanges[name]
+	if !ok || health.online == online {
+		c.Unlock()
+		return
+	}
+	health.online = online
+	onChange := c.onExchangeChange
+	c.Unlock()
+
+	if online {
+		log.Debugf(log.Global, "Exchange %s API reachable\n", name)
+	} else {
+		log.Warnf(log.Global, "Exchange %s API unreachable\n", name)
+	}
+	if onChange != nil {
+		onChange(name, online)
+	}
+}
+
+// CheckExchange requests an exchange health endpoint, any non 2xx or 3xx
+// status, such as a regional block, counts as unreachable
+func (c *Checker) CheckExchange(endpoint string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ExchangeCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s", errExchangeUnhealthy, resp.Status)
+	}
+	return nil
+}
+
+// IsExchangeOnline returns whether the exchange's health endpoint was last
+// reachable. tracked is false when no endpoint is set for the exchange
+func (c *Checker) IsExchangeOnline(exchange string) (online, tracked bool) {
+	c.Lock()
+	defer c.Unlock()
+	health, ok := c.exchanges[strings.ToLower(exchange)]
+	if !ok {
+		return false, false
+	}
+	return health.online, true
+}
+
 // IsConnected returns if there is internet connectivity
 func (c *Checker) IsConnected() bool {
 	c.Lock()
@@ -0,0 +1,88 @@
+package connchecker
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/zhiwei-w-luo/gotradebot/common/testhelpers"
+)
+
+var (
+	up   = testhelpers.Response{Status: http.StatusOK}
+	down = testhelpers.Response{Status: http.StatusServiceUnavailable}
+)
+
+func newStubServer(t *testing.T) *testhelpers.StubServer {
+	t.Helper()
+	srv := testhelpers.NewStubServer()
+	srv.Handle("/ping", up)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIsExchangeOnline(t *testing.T) {
+	t.Parallel()
+	binance := newStubServer(t)
+	kraken := newStubServer(t)
+
+	var mtx sync.Mutex
+	changes := make(map[string][]bool)
+	c := &Checker{}
+	c.SetExchangeEndpoints(map[string]string{
+		"Binance": binance.URL + "/ping",
+		"Kraken":  kraken.URL + "/ping",
+	}, func(exchange string, online bool) {
+		mtx.Lock()
+		changes[exchange] = append(changes[exchange], online)
+		mtx.Unlock()
+	})
+
+	check := func(exchange string, expOnline, expTracked bool) {
+		t.Helper()
+		online, tracked := c.IsExchangeOnline(exchange)
+		if online != expOnline || tracked != expTracked {
+			t.Errorf("%s received: %v %v but expected: %v %v",
+				exchange, online, tracked, expOnline, expTracked)
+		}
+	}
+	check("binance", true, true)
+	check("KRAKEN", true, true)
+	check("bitstamp", false, false)
+
+	binance.Handle("/ping", down)
+	c.exchangeTest()
+	check("binance", false, true)
+	check("kraken", true, true)
+
+	binance.Handle("/ping", up)
+	kraken.Handle("/ping", down)
+	c.exchangeTest()
+	check("binance", true, true)
+	check("kraken", false, true)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(changes["binance"]) != 3 {
+		t.Fatalf("received: %v but expected: %v", changes["binance"], []bool{true, false, true})
+	}
+	if len(changes["kraken"]) != 2 {
+		t.Fatalf("received: %v but expected: %v", changes["kraken"], []bool{true, false})
+	}
+}
+
+func TestCheckExchange(t *testing.T) {
+	t.Parallel()
+	srv := newStubServer(t)
+	c := &Checker{}
+	if err := c.CheckExchange(srv.URL + "/ping"); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	srv.Handle("/ping", down)
+	if err := c.CheckExchange(srv.URL + "/ping"); err == nil {
+		t.Fatal("expected error for unavailable endpoint")
+	}
+	if err := c.CheckExchange("://bad"); err == nil {
+		t.Fatal("expected error for invalid endpoint")
+	}
+}
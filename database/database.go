@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -52,12 +54,84 @@ func (i *Instance) SetPostgresConnection(con *sql.DB) error {
 	i.m.Lock()
 	defer i.m.Unlock()
 	i.SQL = con
-	i.SQL.SetMaxOpenConns(2)
-	i.SQL.SetMaxIdleConns(1)
-	i.SQL.SetConnMaxLifetime(time.Hour)
+	setPostgresPoolLimits(i.SQL)
 	return nil
 }
 
+func setPostgresPoolLimits(con *sql.DB) {
+	con.SetMaxOpenConns(2)
+	con.SetMaxIdleConns(1)
+	con.SetConnMaxLifetime(time.Hour)
+}
+
+// SetReadReplicas replaces the read replica connections, closing any
+// previous ones. Replicas are used by GetReadSQL once a health check passes
+func (i *Instance) SetReadReplicas(ctx context.Context, cons []*sql.DB) error {
+	if i == nil {
+		return ErrNilInstance
+	}
+	replicas := make([]*replica, len(cons))
+	for x := range cons {
+		if cons[x] == nil {
+			return fmt.Errorf("read replica %d: %w", x, errNilSQL)
+		}
+		replicas[x] = &replica{db: cons[x]}
+	}
+	i.m.Lock()
+	previous := i.replicas
+	i.replicas = replicas
+	i.m.Unlock()
+	for x := range previous {
+		_ = previous[x].db.Close()
+	}
+	i.CheckReadReplicas(ctx)
+	return nil
+}
+
+// CheckReadReplicas pings every read replica, taking those which fail out of
+// rotation and returning those which recover. The number of healthy
+// replicas is returned
+func (i *Instance) CheckReadReplicas(ctx context.Context) (healthy int) {
+	if i == nil {
+		return 0
+	}
+	i.m.RLock()
+	replicas := i.replicas
+	i.m.RUnlock()
+	for x := range replicas {
+		ok := replicas[x].db.PingContext(ctx) == nil
+		i.m.Lock()
+		replicas[x].healthy = ok
+		i.m.Unlock()
+		if ok {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// GetReadSQL returns a connection for read only queries, rotating between
+// healthy read replicas. The primary connection is returned when no replicas
+// are configured or none are healthy
+func (i *Instance) GetReadSQL() (*sql.DB, error) {
+	if i == nil {
+		return nil, ErrNilInstance
+	}
+	i.m.RLock()
+	if n := len(i.replicas); n > 0 {
+		start := atomic.AddUint32(&i.nextReplica, 1)
+		for x := 0; x < n; x++ {
+			r := i.replicas[(int(start)+x)%n]
+			if r.healthy {
+				i.m.RUnlock()
+				return r.db, nil
+			}
+		}
+	}
+	i.m.RUnlock()
+	return i.GetSQL()
+}
+
 // SetConnected safely sets the global database instance's connected
 // status
 func (i *Instance) SetConnected(v bool) {
@@ -80,6 +154,10 @@ func (i *Instance) CloseConnection() error {
 	i.m.Lock()
 	defer i.m.Unlock()
 
+	for x := range i.replicas {
+		_ = i.replicas[x].db.Close()
+	}
+	i.replicas = nil
 	return i.SQL.Close()
 }
 
@@ -104,20 +182,25 @@ func (i *Instance) GetConfig() *Config {
 	return cpy
 }
 
-// Ping pings the database
-func (i *Instance) Ping() error {
+// Ping verifies the database connection is alive and records the result as
+// the connected status, so IsConnected reflects the last ping
+func (i *Instance) Ping(ctx context.Context) error {
 	if i == nil {
 		return ErrNilInstance
 	}
-	if !i.IsConnected() {
-		return ErrDatabaseNotConnected
-	}
 	i.m.RLock()
-	defer i.m.RUnlock()
-	if i.SQL == nil {
+	con := i.SQL
+	i.m.RUnlock()
+	if con == nil {
+		i.SetConnected(false)
 		return errNilSQL
 	}
-	return i.SQL.Ping()
+	if err := con.PingContext(ctx); err != nil {
+		i.SetConnected(false)
+		return fmt.Errorf("%w %s", errFailedPing, err)
+	}
+	i.SetConnected(true)
+	return nil
 }
 
 // GetSQL returns the sql connection
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	// import go libpq driver package
 	_ "github.com/lib/pq"
@@ -20,15 +22,7 @@ func Connect(cfg *Config) (*Instance, error) {
 		cfg.SSLMode = "disable"
 	}
 
-	configDSN := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.Username,
-		cfg.Password,
-		cfg.Host,
-		cfg.Port,
-		cfg.Database,
-		cfg.SSLMode)
-
-	db, err := sql.Open(DBPostgreSQL, configDSN)
+	db, err := openPostgres(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -36,5 +30,86 @@ func Connect(cfg *Config) (*Instance, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if len(cfg.ReadReplicas) > 0 {
+		var replicas []*sql.DB
+		replicas, err = openReadReplicas(cfg)
+		if err != nil {
+			return nil, err
+		}
+		// Unreachable replicas are left out of rotation until a health
+		// check passes rather than failing the connection
+		err = DB.SetReadReplicas(context.Background(), replicas)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return DB, nil
 }
+
+// openReadReplicas opens a connection pool for each configured read replica,
+// sharing the primary's settings other than the connection details
+func openReadReplicas(cfg *Config) ([]*sql.DB, error) {
+	replicas := make([]*sql.DB, 0, len(cfg.ReadReplicas))
+	for x := range cfg.ReadReplicas {
+		replicaCfg := *cfg
+		replicaCfg.ConnectionDetails = cfg.ReadReplicas[x]
+		db, err := openPostgres(&replicaCfg)
+		if err != nil {
+			for y := range replicas {
+				_ = replicas[y].Close()
+			}
+			return nil, fmt.Errorf("read replica %s: %w", cfg.ReadReplicas[x].Host, err)
+		}
+		setPostgresPoolLimits(db)
+		replicas = append(replicas, db)
+	}
+	return replicas, nil
+}
+
+func openPostgres(cfg *Config) (*sql.DB, error) {
+	dsn, err := BuildPostgresDSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open(DBPostgreSQL, dsn)
+}
+
+// BuildPostgresDSN returns the connection string for the supplied config,
+// an empty SSLMode is treated as disabled
+func BuildPostgresDSN(cfg *Config) (string, error) {
+	if cfg == nil {
+		return "", ErrNilConfig
+	}
+	sslMode := cfg.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	if err := ValidateSSLMode(sslMode); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
+		cfg.Username,
+		cfg.Password,
+		cfg.Host,
+		cfg.Port,
+		cfg.Database,
+		sslMode), nil
+}
+
+// ValidateSSLMode checks that mode is recognised by libpq, an empty mode is
+// accepted as it defaults to disabled
+func ValidateSSLMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for x := range SupportedSSLModes {
+		if mode == SupportedSSLModes[x] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q, valid modes are: %s",
+		errInvalidSSLMode,
+		mode,
+		strings.Join(SupportedSSLModes, ", "))
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/common"
+)
+
+// ExecWithRetry executes a query without returning rows, retrying with
+// backoff while the error is transient. Non transient errors such as
+// constraint violations are returned straight away
+func (i *Instance) ExecWithRetry(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := i.withRetry(ctx, func(con *sql.DB) error {
+		var err error
+		result, err = con.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// QueryWithRetry executes a query returning rows, retrying with backoff while
+// the error is transient. Retries only happen before rows are returned, the
+// caller is responsible for closing them
+func (i *Instance) QueryWithRetry(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := i.withRetry(ctx, func(con *sql.DB) error {
+		var err error
+		rows, err = con.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// recordQuery adds a query attempt to the instance's stats
+func (i *Instance) recordQuery(latency time.Duration, err error) {
+	atomic.AddUint64(&i.stats.queries, 1)
+	atomic.AddInt64(&i.stats.latency, int64(latency))
+	if err != nil {
+		atomic.AddUint64(&i.stats.errors, 1)
+	}
+}
+
+// Stats returns the query counts and average latency of the instance's
+// instrumented query methods
+func (i *Instance) Stats() DBStats {
+	if i == nil {
+		return DBStats{}
+	}
+	s := DBStats{
+		Queries: atomic.LoadUint64(&i.stats.queries),
+		Errors:  atomic.LoadUint64(&i.stats.errors),
+	}
+	if s.Queries > 0 {
+		s.AvgLatency = time.Duration(atomic.LoadInt64(&i.stats.latency) / int64(s.Queries))
+	}
+	return s
+}
+
+// withRetry calls fn until it succeeds, returns a non retryable error, the
+// context is done or the attempts are used up. Between attempts the
+// connection is pinged so the connected status is kept current
+func (i *Instance) withRetry(ctx context.Context, fn func(*sql.DB) error) error {
+	if i == nil {
+		return ErrNilInstance
+	}
+	cfg := i.retryConfig()
+	backoff := common.Backoff{
+		Base:   cfg.InitialBackoff,
+		Factor: 2,
+		Max:    cfg.MaxBackoff,
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		var con *sql.DB
+		con, err = i.GetSQL()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = fn(con)
+		i.recordQuery(time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+		if !isRetryableError(err, cfg.RetryableErrors) {
+			return err
+		}
+		if attempt >= cfg.MaxAttempts {
+			return fmt.Errorf("%w after %d attempts: %v", ErrRetriesExhausted, attempt, err)
+		}
+
+		if ctxErr := common.SleepCtx(ctx, backoff.Next()); ctxErr != nil {
+			return fmt.Errorf("%w, last error: %v", ctxErr, err)
+		}
+		// A failed ping is not fatal, the next attempt reports the real error
+		_ = i.Ping(ctx)
+	}
+}
+
+// retryConfig returns the configured retry settings with defaults applied
+func (i *Instance) retryConfig() RetryConfig {
+	var cfg RetryConfig
+	if c := i.GetConfig(); c != nil {
+		cfg = c.Retry
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = cfg.InitialBackoff
+	}
+	return cfg
+}
+
+// isRetryableError reports whether err is a transient connection error,
+// either by wrapping a known transient error or by matching the default or
+// additional error strings
+func isRetryableError(err error, additional []string) bool {
+	if err == nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for x := range defaultRetryableErrors {
+		if strings.Contains(msg, defaultRetryableErrors[x]) {
+			return true
+		}
+	}
+	for x := range additional {
+		if additional[x] != "" && strings.Contains(msg, strings.ToLower(additional[x])) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 var (
@@ -31,6 +32,23 @@ var (
 	ErrNilConfig  = errors.New("received nil config")
 	errNilSQL     = errors.New("database SQL connection is nil")
 	errFailedPing = errors.New("unable to verify database is connected, failed ping")
+	// SupportedSSLModes slice of sslmode values recognised by libpq
+	SupportedSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	errInvalidSSLMode = errors.New("invalid database sslmode")
+	// ErrRetriesExhausted for when a retryable query keeps failing
+	ErrRetriesExhausted = errors.New("database retries exhausted")
+
+	// defaultRetryableErrors are matched against the text of errors which do
+	// not wrap a known transient error, such as those returned by lib/pq
+	defaultRetryableErrors = []string{
+		"connection reset by peer",
+		"broken pipe",
+		"connection refused",
+		"bad connection",
+		"the database system is starting up",
+		"the database system is shutting down",
+		"terminating connection due to administrator command",
+	}
 )
 
 const (
@@ -38,17 +56,52 @@ const (
 	DBPostgreSQL = "postgres"
 	// DBInvalidDriver const string for invalid driver
 	DBInvalidDriver = "invalid driver"
+
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = time.Millisecond * 100
+	defaultRetryMaxBackoff     = time.Second * 2
 )
 
 // Instance holds all information for a database instance
 type Instance struct {
+	// stats is first so its 64 bit counters are aligned for atomic access
+	// on 32 bit platforms
+	stats queryStats
+
 	SQL       *sql.DB
 	DataPath  string
 	config    *Config
 	connected bool
 	m         sync.RWMutex
+
+	// replicas are read only connections used by GetReadSQL
+	replicas    []*replica
+	nextReplica uint32
 }
 
+// queryStats counts queries made through ExecWithRetry and QueryWithRetry,
+// all fields are accessed atomically
+type queryStats struct {
+	queries uint64
+	errors  uint64
+	// latency is the total query time in nanoseconds
+	latency int64
+}
+
+// DBStats is a snapshot of the queries made through an instance's
+// instrumented query methods, each retry attempt counts as a query
+type DBStats struct {
+	Queries    uint64        `json:"queries"`
+	Errors     uint64        `json:"errors"`
+	AvgLatency time.Duration `json:"avgLatency"`
+}
+
+// replica is a read replica connection and whether its last health check
+// passed
+type replica struct {
+	db      *sql.DB
+	healthy bool
+}
 
 // ConnectionDetails holds DSN information
 type ConnectionDetails struct {
@@ -62,19 +115,33 @@ type ConnectionDetails struct {
 
 // Config holds all database configurable options including enable/disabled & DSN settings
 type Config struct {
-	Enabled                   bool   `json:"enabled"`
-	Verbose                   bool   `json:"verbose"`
-	Driver                    string `json:"driver"`
+	Enabled           bool   `json:"enabled"`
+	Verbose           bool   `json:"verbose"`
+	Driver            string `json:"driver"`
 	ConnectionDetails `json:"connectionDetails"`
+	Retry             RetryConfig `json:"retry"`
+	// ReadReplicas are optional read only databases which GetReadSQL spreads
+	// read queries across, writes and transactions always use the primary
+	ReadReplicas []ConnectionDetails `json:"readReplicas,omitempty"`
 }
 
-
+// RetryConfig defines how ExecWithRetry and QueryWithRetry handle transient
+// errors, zero values fall back to the defaults
+type RetryConfig struct {
+	MaxAttempts    int           `json:"maxAttempts"`
+	InitialBackoff time.Duration `json:"initialBackoff"`
+	MaxBackoff     time.Duration `json:"maxBackoff"`
+	// RetryableErrors are additional case insensitive substrings which mark
+	// an error as transient
+	RetryableErrors []string `json:"retryableErrors,omitempty"`
+}
 
 // IDatabase allows for the passing of a database struct
 // without giving the receiver access to all functionality
 type IDatabase interface {
 	IsConnected() bool
 	GetSQL() (*sql.DB, error)
+	GetReadSQL() (*sql.DB, error)
 	GetConfig() *Config
 }
 
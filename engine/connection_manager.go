@@ -80,6 +80,10 @@ func (m *connectionManager) Start() error {
 		return err
 	}
 
+	if len(m.cfg.ExchangeHealthEndpoints) > 0 {
+		m.conn.SetExchangeEndpoints(m.cfg.ExchangeHealthEndpoints, nil)
+	}
+
 	log.Debugln(log.ConnectionMgr, "Connection manager started.")
 	return nil
 }
@@ -116,3 +120,15 @@ func (m *connectionManager) IsOnline() bool {
 
 	return m.conn.IsConnected()
 }
+
+// IsExchangeOnline returns whether the exchange is reachable. Exchanges
+// without a configured health endpoint follow general connectivity
+func (m *connectionManager) IsExchangeOnline(name string) bool {
+	if m == nil || m.conn == nil {
+		return false
+	}
+	if online, tracked := m.conn.IsExchangeOnline(name); tracked {
+		return online
+	}
+	return m.conn.IsConnected()
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -16,11 +17,18 @@ const DatabaseConnectionManagerName = "database"
 
 // DatabaseConnectionManager holds the database connection and its status
 type DatabaseConnectionManager struct {
-	started  int32
-	shutdown chan struct{}
-	cfg      database.Config
-	wg       sync.WaitGroup
-	dbConn   *database.Instance
+	started int32
+	ctx     context.Context
+	cancel  context.CancelFunc
+	cfg     database.Config
+	wg      sync.WaitGroup
+	dbConn  *database.Instance
+	// healthyReplicas is the number of read replicas in rotation after the
+	// last check, only accessed by the run routine
+	healthyReplicas int
+	// ready is closed once the first connection has been established
+	ready     chan struct{}
+	readyOnce sync.Once
 }
 
 // IsRunning safely checks whether the subsystem is running
@@ -46,9 +54,9 @@ func SetupDatabaseConnectionManager(cfg *database.Config) (*DatabaseConnectionMa
 		return nil, errNilConfig
 	}
 	m := &DatabaseConnectionManager{
-		shutdown: make(chan struct{}),
-		cfg:      *cfg,
-		dbConn:   database.DB,
+		cfg:    *cfg,
+		dbConn: database.DB,
+		ready:  make(chan struct{}),
 	}
 	if err := m.dbConn.SetConfig(cfg); err != nil {
 		return nil, err
@@ -57,6 +65,33 @@ func SetupDatabaseConnectionManager(cfg *database.Config) (*DatabaseConnectionMa
 	return m, nil
 }
 
+// Stats returns query counts and latency for the database connection, the
+// zero value is returned when the manager is not running
+func (m *DatabaseConnectionManager) Stats() database.DBStats {
+	if m == nil || atomic.LoadInt32(&m.started) == 0 {
+		return database.DBStats{}
+	}
+	return m.dbConn.Stats()
+}
+
+// WaitReady blocks until the database connection has been established, it
+// is used to hold back subsystems which read from the database. An error is
+// returned straight away if the manager has not been started
+func (m *DatabaseConnectionManager) WaitReady(ctx context.Context) error {
+	if m == nil {
+		return fmt.Errorf("%s %w", DatabaseConnectionManagerName, ErrNilSubsystem)
+	}
+	if atomic.LoadInt32(&m.started) == 0 {
+		return fmt.Errorf("%s %w", DatabaseConnectionManagerName, ErrSubSystemNotStarted)
+	}
+	select {
+	case <-m.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // IsConnected is an exported check to verify if the database is connected
 func (m *DatabaseConnectionManager) IsConnected() bool {
 	if m == nil || atomic.LoadInt32(&m.started) == 0 {
@@ -85,7 +120,6 @@ func (m *DatabaseConnectionManager) Start(wg *sync.WaitGroup) (err error) {
 	log.Debugln(log.DatabaseMgr, "Database manager starting...")
 
 	if m.cfg.Enabled {
-		m.shutdown = make(chan struct{})
 		log.Debugf(log.DatabaseMgr,
 			"Attempting to establish database connection to host %s/%s utilising %s driver\n",
 			m.cfg.Host,
@@ -97,6 +131,8 @@ func (m *DatabaseConnectionManager) Start(wg *sync.WaitGroup) (err error) {
 			return fmt.Errorf("%w: %v Some features that utilise a database will be unavailable", database.ErrFailedToConnect, err)
 		}
 		m.dbConn.SetConnected(true)
+		m.readyOnce.Do(func() { close(m.ready) })
+		m.ctx, m.cancel = context.WithCancel(context.Background())
 		wg.Add(1)
 		m.wg.Add(1)
 		go m.run(wg)
@@ -119,36 +155,30 @@ func (m *DatabaseConnectionManager) Stop() error {
 		atomic.CompareAndSwapInt32(&m.started, 1, 0)
 	}()
 
+	// Cancelling interrupts any in flight connection check so the routine
+	// exits before the connection is closed underneath it
+	m.cancel()
+	m.wg.Wait()
+
 	err := m.dbConn.CloseConnection()
 	if err != nil {
 		log.Errorf(log.DatabaseMgr, "Failed to close database: %v", err)
 	}
-
-	close(m.shutdown)
-	m.wg.Wait()
 	return nil
 }
 
 func (m *DatabaseConnectionManager) run(wg *sync.WaitGroup) {
 	log.Debugln(log.DatabaseMgr, "Database manager started.")
-	t := time.NewTicker(time.Second * 2)
-
 	defer func() {
-		t.Stop()
 		m.wg.Done()
 		wg.Done()
 		log.Debugln(log.DatabaseMgr, "Database manager shutdown.")
 	}()
 
-	for {
-		select {
-		case <-m.shutdown:
-			return
-		case <-t.C:
-			err := m.checkConnection()
-			if err != nil {
-				log.Error(log.DatabaseMgr, "Database connection error:", err)
-			}
+	for range common.NewManagedTicker(m.ctx, time.Second*2) {
+		err := m.checkConnection()
+		if err != nil && m.ctx.Err() == nil {
+			log.Error(log.DatabaseMgr, "Database connection error:", err)
 		}
 	}
 }
@@ -167,14 +197,40 @@ func (m *DatabaseConnectionManager) checkConnection() error {
 		return database.ErrNoDatabaseProvided
 	}
 
-	if err := m.dbConn.Ping(); err != nil {
-		m.dbConn.SetConnected(false)
+	wasConnected := m.dbConn.IsConnected()
+	parent := m.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, time.Second*2)
+	defer cancel()
+	if len(m.cfg.ReadReplicas) > 0 {
+		m.checkReadReplicas(ctx)
+	}
+	if err := m.dbConn.Ping(ctx); err != nil {
 		return err
 	}
 
-	if !m.dbConn.IsConnected() {
+	if !wasConnected {
 		log.Info(log.DatabaseMgr, "Database connection reestablished")
-		m.dbConn.SetConnected(true)
 	}
 	return nil
 }
+
+// checkReadReplicas updates which read replicas are in rotation, logging when
+// the number of healthy replicas changes
+func (m *DatabaseConnectionManager) checkReadReplicas(ctx context.Context) {
+	healthy := m.dbConn.CheckReadReplicas(ctx)
+	if healthy == m.healthyReplicas {
+		return
+	}
+	switch {
+	case healthy == 0:
+		log.Warnf(log.DatabaseMgr, "No read replicas available, reads falling back to the primary database\n")
+	case healthy < m.healthyReplicas:
+		log.Warnf(log.DatabaseMgr, "Read replicas in rotation reduced to %d of %d\n", healthy, len(m.cfg.ReadReplicas))
+	default:
+		log.Infof(log.DatabaseMgr, "Read replicas in rotation increased to %d of %d\n", healthy, len(m.cfg.ReadReplicas))
+	}
+	m.healthyReplicas = healthy
+}
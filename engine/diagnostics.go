@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+const (
+	// maxStackDumpSize caps the goroutine stack dump included in diagnostics
+	maxStackDumpSize = 4 << 20
+	// DiagnosticsPath is the route in the API server's health namespace
+	// served by DiagnosticsHandler
+	DiagnosticsPath = "/health/diagnostics"
+)
+
+// Diagnostics is a point in time snapshot of the engine's runtime state,
+// suitable for serialising into a support bundle
+type Diagnostics struct {
+	Timestamp       time.Time         `json:"timestamp"`
+	Uptime          time.Duration     `json:"uptime"`
+	GoVersion       string            `json:"goVersion"`
+	Goroutines      int               `json:"goroutines"`
+	GoMaxProcs      int               `json:"goMaxProcs"`
+	LogicalCPUs     int               `json:"logicalCPUs"`
+	Memory          MemoryDiagnostics `json:"memory"`
+	Subsystems      map[string]bool   `json:"subsystems"`
+	Online          bool              `json:"online"`
+	ClockJumps      uint64            `json:"clockJumps"`
+	AbandonedStarts []AbandonedStart  `json:"abandonedStarts,omitempty"`
+	GoroutineStack  string            `json:"goroutineStack,omitempty"`
+}
+
+// MemoryDiagnostics holds the subset of runtime.MemStats useful for support
+type MemoryDiagnostics struct {
+	Alloc        uint64        `json:"alloc"`
+	TotalAlloc   uint64        `json:"totalAlloc"`
+	Sys          uint64        `json:"sys"`
+	HeapAlloc    uint64        `json:"heapAlloc"`
+	HeapInuse    uint64        `json:"heapInuse"`
+	HeapObjects  uint64        `json:"heapObjects"`
+	NumGC        uint32        `json:"numGC"`
+	PauseTotal   time.Duration `json:"pauseTotal"`
+	LastGC       time.Time     `json:"lastGC"`
+	GCCPUPercent float64       `json:"gcCPUPercent"`
+}
+
+// Diagnostics returns a snapshot of the engine's runtime state. The stack of
+// every goroutine is included when Settings.EnableDiagnosticsStackDump is set
+func (bot *Engine) Diagnostics() Diagnostics {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	d := Diagnostics{
+		Timestamp:   time.Now(),
+		GoVersion:   runtime.Version(),
+		Goroutines:  runtime.NumGoroutine(),
+		GoMaxProcs:  runtime.GOMAXPROCS(-1),
+		LogicalCPUs: runtime.NumCPU(),
+		Memory: MemoryDiagnostics{
+			Alloc:        mem.Alloc,
+			TotalAlloc:   mem.TotalAlloc,
+			Sys:          mem.Sys,
+			HeapAlloc:    mem.HeapAlloc,
+			HeapInuse:    mem.HeapInuse,
+			HeapObjects:  mem.HeapObjects,
+			NumGC:        mem.NumGC,
+			PauseTotal:   time.Duration(mem.PauseTotalNs),
+			GCCPUPercent: mem.GCCPUFraction * 100,
+		},
+		Subsystems:      bot.GetSubsystemsStatus(),
+		Online:          bot.connectionManager.IsOnline(),
+		AbandonedStarts: bot.AbandonedStarts(),
+	}
+	if bot.clockJumps != nil {
+		d.ClockJumps = bot.clockJumps.Jumps()
+	}
+	if mem.LastGC > 0 {
+		d.Memory.LastGC = time.Unix(0, int64(mem.LastGC))
+	}
+	if !bot.uptime.IsZero() {
+		d.Uptime = time.Since(bot.uptime)
+	}
+	if bot.Settings.EnableDiagnosticsStackDump {
+		d.GoroutineStack = goroutineStacks()
+	}
+	return d
+}
+
+// DiagnosticsHandler serves the engine diagnostics as JSON, it is registered
+// by the API server at DiagnosticsPath
+func (bot *Engine) DiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(bot.Diagnostics()); err != nil {
+		log.Errorf(log.APIServerMgr, "Unable to write diagnostics response. Error: %v\n", err)
+	}
+}
+
+// GetSubsystemsStatus returns whether each engine subsystem is running
+func (bot *Engine) GetSubsystemsStatus() map[string]bool {
+	return map[string]bool{
+		DatabaseConnectionManagerName: bot.DatabaseManager.IsRunning(),
+		ConnectionManagerName:         bot.connectionManager.IsRunning(),
+		"communications":              bot.CommunicationsManager.IsRunning(),
+		"portfolio":                   bot.portfolioManager.IsRunning(),
+		"orders":                      bot.OrderManager.IsRunning(),
+		"events":                      bot.eventManager.IsRunning(),
+		"ntp_timekeeper":              bot.ntpManager.IsRunning(),
+		"data_history_manager":        bot.dataHistoryManager.IsRunning(),
+		"websocket_routine":           bot.websocketRoutineManager.IsRunning(),
+		"gctscript":                   bot.gctScriptManager.IsRunning(),
+		CurrencyStateManagementName:   bot.currencyStateManager.IsRunning(),
+		"deprecated_rpc":              bot.apiServer.IsRESTServerRunning(),
+		"websocket_rpc":               bot.apiServer.IsWebsocketServerRunning(),
+	}
+}
+
+// goroutineStacks returns the formatted stack of every goroutine, growing
+// the buffer until the dump fits or the cap is reached
+func goroutineStacks() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStackDumpSize {
+			return string(buf[:n])
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
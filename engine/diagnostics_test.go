@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{Settings: Settings{EnableDiagnosticsStackDump: true}}
+
+	rec := httptest.NewRecorder()
+	bot.DiagnosticsHandler(rec, httptest.NewRequest(http.MethodGet, DiagnosticsPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("received: %d but expected: %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("received: %q but expected: %q", ct, "application/json")
+	}
+	var d Diagnostics
+	if err := json.NewDecoder(rec.Body).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Goroutines == 0 || d.GoMaxProcs == 0 || d.GoVersion == "" {
+		t.Errorf("received: %+v but expected runtime details", d)
+	}
+	if _, ok := d.Subsystems[DatabaseConnectionManagerName]; !ok {
+		t.Error("expected subsystem status to be included")
+	}
+	if !strings.Contains(d.GoroutineStack, "goroutine") {
+		t.Error("expected a goroutine stack dump")
+	}
+
+	rec = httptest.NewRecorder()
+	bot.DiagnosticsHandler(rec, httptest.NewRequest(http.MethodPost, DiagnosticsPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("received: %d but expected: %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -1,9 +1,9 @@
 package engine
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -11,8 +11,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/zhiwei-w-luo/gotradebot/common"
+	"github.com/zhiwei-w-luo/gotradebot/common/datadir"
 	"github.com/zhiwei-w-luo/gotradebot/config"
-
+	gctlog "github.com/zhiwei-w-luo/gotradebot/log"
 )
 
 // Engine contains configuration, portfolio manager, exchange & ticker data and is the
@@ -22,8 +24,20 @@ type Engine struct {
 	connectionManager *connectionManager
 	DatabaseManager   *DatabaseConnectionManager
 	Settings          Settings
-	uptime            time.Time
-	ServicesWG        sync.WaitGroup
+	settingsMtx       sync.Mutex
+	// configMtx guards changes made to Config while the engine is running
+	configMtx  sync.Mutex
+	dataDir    *datadir.Layout
+	uptime     time.Time
+	ServicesWG sync.WaitGroup
+	// clockJumps detects host suspend and resume, subsystems holding time
+	// sensitive state subscribe to it
+	clockJumps     *common.ClockJumpDetector
+	stopClockJumps context.CancelFunc
+	// abandonedStarts are subsystem starts which timed out under
+	// ContinueOnSubsystemError
+	abandonedStarts []AbandonedStart
+	abandonedMtx    sync.Mutex
 }
 
 // Bot is a happy global engine to allow various areas of the application
@@ -53,20 +67,42 @@ func NewFromSettings(settings *Settings, flagSet map[string]bool) (*Engine, erro
 		return nil, errors.New("engine: settings is nil")
 	}
 
-	var b Engine
-	var err error
+	// Messages logged until the configured logger is set up, such as the
+	// config being loaded, go to stdout with the default formatting
+	err := setupBootstrapLogger()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup bootstrap logger. %w", err)
+	}
 
+	var b Engine
 	b.Config, err = loadConfigWithSettings(settings, flagSet)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config. Err: %w", err)
 	}
 
+	b.Settings.DataDir = b.Config.GetDataPath()
+	b.dataDir, err = datadir.New(b.Settings.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data directory. Err: %w", err)
+	}
+	// Runs ahead of the configured logger, the dry run's planned moves are
+	// written to stdout by the bootstrap logger
+	_, err = b.dataDir.Migrate(settings.EnableDryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate data directory layout. Err: %w", err)
+	}
+	gctlog.LogPath = b.dataDir.Logs()
+
+	gctlog.RWM.Lock()
+	gctlog.GlobalLogConfig = &b.Config.Logging
+	gctlog.FileLoggingConfiguredCorrectly = fileLoggingReady(&b.Config.Logging)
+	gctlog.RWM.Unlock()
 	if *b.Config.Logging.Enabled {
 		err = gctlog.SetupGlobalLogger()
 		if err != nil {
 			return nil, fmt.Errorf("failed to setup global logger. %w", err)
 		}
-		err = gctlog.SetupSubLoggers(b.Config.Logging.SubLoggers)
+		_, err = gctlog.SetupSubLoggers(b.Config.Logging.SubLoggers)
 		if err != nil {
 			return nil, fmt.Errorf("failed to setup sub loggers. %w", err)
 		}
@@ -74,7 +110,6 @@ func NewFromSettings(settings *Settings, flagSet map[string]bool) (*Engine, erro
 	}
 
 	b.Settings.ConfigFile = settings.ConfigFile
-	b.Settings.DataDir = b.Config.GetDataPath()
 	b.Settings.CheckParamInteraction = settings.CheckParamInteraction
 
 	err = utils.AdjustGoMaxProcs(settings.GoMaxProcs)
@@ -90,19 +125,34 @@ func NewFromSettings(settings *Settings, flagSet map[string]bool) (*Engine, erro
 	b.ExchangeManager = SetupExchangeManager()
 
 	validateSettings(&b, settings, flagSet)
+	err = b.Settings.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings. Err: %w", err)
+	}
 
 	return &b, nil
 }
 
+// setupBootstrapLogger points every logger at stdout using the default log
+// settings, the configured logger replaces it once the config is loaded
+func setupBootstrapLogger() error {
+	gctlog.RWM.Lock()
+	gctlog.GlobalLogConfig = gctlog.GenDefaultSettings()
+	gctlog.FileLoggingConfiguredCorrectly = false
+	gctlog.RWM.Unlock()
+	return gctlog.SetupGlobalLogger()
+}
+
 // loadConfigWithSettings creates configuration based on the provided settings
 func loadConfigWithSettings(settings *Settings, flagSet map[string]bool) (*config.Config, error) {
 	filePath, err := config.GetAndMigrateDefaultPath(settings.ConfigFile)
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Loading config file %s..\n", filePath)
+	gctlog.Infof(gctlog.ConfigMgr, "Loading config file %s..\n", filePath)
 
 	conf := &config.Config{}
+	conf.SetReadOnly(settings.ReadOnlyConfig)
 	err = conf.ReadConfigFromFile(filePath, settings.EnableDryRun)
 	if err != nil {
 		return nil, fmt.Errorf(config.ErrFailureOpeningConfig, filePath, err)
@@ -111,7 +161,7 @@ func loadConfigWithSettings(settings *Settings, flagSet map[string]bool) (*confi
 	if flagSet["datadir"] {
 		// warn if dryrun isn't enabled
 		if !settings.EnableDryRun {
-			log.Println("Command line argument '-datadir' induces dry run mode.")
+			gctlog.Warnln(gctlog.ConfigMgr, "Command line argument '-datadir' induces dry run mode.")
 		}
 		settings.EnableDryRun = true
 		conf.DataDirectory = settings.DataDir
@@ -129,12 +179,36 @@ func (bot *Engine) Start() error {
 	newEngineMutex.Lock()
 	defer newEngineMutex.Unlock()
 
+	bot.registerSensitiveValues()
+
+	if err = bot.Config.CheckGlobalHTTPTimeout(); err != nil {
+		return err
+	}
+	httpTimeout := bot.Config.GlobalHTTPTimeout
+	if bot.Settings.GlobalHTTPTimeout > 0 {
+		httpTimeout = bot.Settings.GlobalHTTPTimeout
+	}
+	if httpTimeout > 0 {
+		if err = common.SetHTTPClientWithTimeout(httpTimeout); err != nil {
+			return err
+		}
+		gctlog.Debugf(gctlog.Global, "Global HTTP client timeout set to %s\n", httpTimeout)
+	}
+	for exchName, proxy := range bot.Config.ExchangeHTTPProxies {
+		if err = common.SetExchangeHTTPProxy(exchName, proxy, httpTimeout); err != nil {
+			return fmt.Errorf("%s HTTP proxy: %w", exchName, err)
+		}
+		gctlog.Debugf(gctlog.Global, "%s HTTP requests sent through proxy\n", exchName)
+	}
+
 	if bot.Settings.EnableDatabaseManager {
 		bot.DatabaseManager, err = SetupDatabaseConnectionManager(&bot.Config.Database)
 		if err != nil {
 			gctlog.Errorf(gctlog.Global, "Database manager unable to setup: %v", err)
 		} else {
-			err = bot.DatabaseManager.Start(&bot.ServicesWG)
+			err = bot.startWithWatchdog(DatabaseConnectionManagerName, func() error {
+				return bot.DatabaseManager.Start(&bot.ServicesWG)
+			}, bot.DatabaseManager.Stop)
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "Database manager unable to start: %v", err)
 			}
@@ -153,7 +227,9 @@ func (bot *Engine) Start() error {
 		if err != nil {
 			gctlog.Errorf(gctlog.Global, "Connection manager unable to setup: %v", err)
 		} else {
-			err = bot.connectionManager.Start()
+			err = bot.startWithWatchdog(ConnectionManagerName, func() error {
+				return bot.connectionManager.Start()
+			}, bot.connectionManager.Stop)
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "Connection manager unable to start: %v", err)
 			}
@@ -175,26 +251,25 @@ func (bot *Engine) Start() error {
 		}
 	}
 
-	bot.uptime = time.Now()
-	gctlog.Debugf(gctlog.Global, "Bot '%s' started.\n", bot.Config.Name)
-	gctlog.Debugf(gctlog.Global, "Using data dir: %s\n", bot.Settings.DataDir)
-	if *bot.Config.Logging.Enabled && strings.Contains(bot.Config.Logging.Output, "file") {
-		gctlog.Debugf(gctlog.Global, "Using log file: %s\n",
-			filepath.Join(gctlog.LogPath, bot.Config.Logging.LoggerFileConfig.FileName))
+	bot.clockJumps, err = common.NewClockJumpDetector(clockJumpCheckInterval, clockJumpThreshold)
+	if err != nil {
+		return err
 	}
-	gctlog.Debugf(gctlog.Global,
-		"Using %d out of %d logical processors for runtime performance\n",
-		runtime.GOMAXPROCS(-1), runtime.NumCPU())
+	var clockJumpsCtx context.Context
+	clockJumpsCtx, bot.stopClockJumps = context.WithCancel(context.Background())
+	bot.ServicesWG.Add(1)
+	go func() {
+		defer bot.ServicesWG.Done()
+		bot.clockJumps.Run(clockJumpsCtx)
+	}()
 
-	enabledExchanges := bot.Config.CountEnabledExchanges()
-	if bot.Settings.EnableAllExchanges {
-		enabledExchanges = len(bot.Config.Exchanges)
+	bot.uptime = time.Now()
+	if bot.Settings.QuietStartup {
+		logStartupFacts(gctlog.Global, bot.Summary())
+	} else {
+		bot.PrintStartupBanner()
 	}
 
-	gctlog.Debugln(gctlog.Global, "EXCHANGE COVERAGE")
-	gctlog.Debugf(gctlog.Global, "\t Available Exchanges: %d. Enabled Exchanges: %d.\n",
-		len(bot.Config.Exchanges), enabledExchanges)
-
 	if bot.Settings.ExchangePurgeCredentials {
 		gctlog.Debugln(gctlog.Global, "Purging exchange API credentials.")
 		bot.Config.PurgeExchangeAPICredentials()
@@ -211,7 +286,9 @@ func (bot *Engine) Start() error {
 		if err != nil {
 			gctlog.Errorf(gctlog.Global, "Communications manager unable to setup: %s", err)
 		} else {
-			err = bot.CommunicationsManager.Start()
+			err = bot.startWithWatchdog("communications manager", func() error {
+				return bot.CommunicationsManager.Start()
+			}, bot.CommunicationsManager.Stop)
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "Communications manager unable to start: %s", err)
 			}
@@ -243,7 +320,9 @@ func (bot *Engine) Start() error {
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "portfolio manager unable to setup: %s", err)
 			} else {
-				err = bot.portfolioManager.Start(&bot.ServicesWG)
+				err = bot.startWithWatchdog("portfolio manager", func() error {
+					return bot.portfolioManager.Start(&bot.ServicesWG)
+				}, bot.portfolioManager.Stop)
 				if err != nil {
 					gctlog.Errorf(gctlog.Global, "portfolio manager unable to start: %s", err)
 				}
@@ -257,7 +336,12 @@ func (bot *Engine) Start() error {
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "database history manager unable to setup: %s", err)
 			} else {
-				err = bot.dataHistoryManager.Start()
+				if err = bot.waitForDependency("data history manager", DatabaseConnectionManagerName, bot.DatabaseManager); err != nil {
+					gctlog.Warnln(gctlog.Global, err)
+				}
+				err = bot.startWithWatchdog("data history manager", func() error {
+					return bot.dataHistoryManager.Start()
+				}, bot.dataHistoryManager.Stop)
 				if err != nil {
 					gctlog.Errorf(gctlog.Global, "database history manager unable to start: %s", err)
 				}
@@ -281,13 +365,17 @@ func (bot *Engine) Start() error {
 			gctlog.Errorf(gctlog.Global, "API Server unable to start: %s", err)
 		} else {
 			if bot.Settings.EnableDeprecatedRPC {
-				err = bot.apiServer.StartRESTServer()
+				err = bot.startWithWatchdog("REST API server", func() error {
+					return bot.apiServer.StartRESTServer()
+				}, bot.apiServer.StopRESTServer)
 				if err != nil {
 					gctlog.Errorf(gctlog.Global, "could not start REST API server: %s", err)
 				}
 			}
 			if bot.Settings.EnableWebsocketRPC {
-				err = bot.apiServer.StartWebsocketServer()
+				err = bot.startWithWatchdog("websocket API server", func() error {
+					return bot.apiServer.StartWebsocketServer()
+				}, bot.apiServer.StopWebsocketServer)
 				if err != nil {
 					gctlog.Errorf(gctlog.Global, "could not start websocket API server: %s", err)
 				}
@@ -314,7 +402,9 @@ func (bot *Engine) Start() error {
 		if err != nil {
 			gctlog.Errorf(gctlog.Global, "Order manager unable to setup: %s", err)
 		} else {
-			err = bot.OrderManager.Start()
+			err = bot.startWithWatchdog("order manager", func() error {
+				return bot.OrderManager.Start()
+			}, bot.OrderManager.Stop)
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "Order manager unable to start: %s", err)
 			}
@@ -357,7 +447,14 @@ func (bot *Engine) Start() error {
 		if err != nil {
 			gctlog.Errorf(gctlog.Global, "Unable to initialise event manager. Err: %s", err)
 		} else {
-			err = bot.eventManager.Start()
+			if bot.Settings.EnableOrderManager {
+				if err = bot.waitForDependency("event manager", "order manager", bot.OrderManager); err != nil {
+					gctlog.Warnln(gctlog.Global, err)
+				}
+			}
+			err = bot.startWithWatchdog("event manager", func() error {
+				return bot.eventManager.Start()
+			}, bot.eventManager.Stop)
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "failed to start event manager. Err: %s", err)
 			}
@@ -369,7 +466,14 @@ func (bot *Engine) Start() error {
 		if err != nil {
 			gctlog.Errorf(gctlog.Global, "Unable to initialise websocket routine manager. Err: %s", err)
 		} else {
-			err = bot.websocketRoutineManager.Start()
+			// The sync manager starts in its own routine, wait for it so
+			// websocket updates are not dropped before it is tracking pairs
+			if err = bot.waitForDependency("websocket routine manager", "exchange syncer", bot.currencyPairSyncer); err != nil {
+				gctlog.Warnln(gctlog.Global, err)
+			}
+			err = bot.startWithWatchdog("websocket routine manager", func() error {
+				return bot.websocketRoutineManager.Start()
+			}, bot.websocketRoutineManager.Stop)
 			if err != nil {
 				gctlog.Errorf(gctlog.Global, "failed to start websocket routine manager. Err: %s", err)
 			}
@@ -381,7 +485,9 @@ func (bot *Engine) Start() error {
 		if err != nil {
 			gctlog.Errorf(gctlog.Global, "failed to create script manager. Err: %s", err)
 		}
-		if err = bot.gctScriptManager.Start(&bot.ServicesWG); err != nil {
+		if err = bot.startWithWatchdog("GCTScript manager", func() error {
+			return bot.gctScriptManager.Start(&bot.ServicesWG)
+		}, bot.gctScriptManager.Stop); err != nil {
 			gctlog.Errorf(gctlog.Global, "GCTScript manager unable to start: %s", err)
 		}
 	}
@@ -396,7 +502,9 @@ func (bot *Engine) Start() error {
 				CurrencyStateManagementName,
 				err)
 		} else {
-			err = bot.currencyStateManager.Start()
+			err = bot.startWithWatchdog(CurrencyStateManagementName, func() error {
+				return bot.currencyStateManager.Start()
+			}, bot.currencyStateManager.Stop)
 			if err != nil {
 				gctlog.Errorf(gctlog.Global,
 					"%s unable to start: %s",
@@ -408,6 +516,52 @@ func (bot *Engine) Start() error {
 	return nil
 }
 
+// quiescer is implemented by subsystems which can stop accepting new work
+// and drain their in flight tasks ahead of a full shutdown. As with
+// IsRunning, Quiesce must be safe to call on a nil receiver
+type quiescer interface {
+	Quiesce() error
+}
+
+// Quiesce is the first phase of a graceful shutdown, the order, sync and
+// websocket routine managers are told to stop accepting new work and to
+// finish their in flight tasks. Subsystems which do not implement Quiesce
+// are skipped. Stop performs the hard teardown afterwards
+func (bot *Engine) Quiesce() error {
+	newEngineMutex.Lock()
+	defer newEngineMutex.Unlock()
+
+	gctlog.Debugln(gctlog.Global, "Engine no longer accepting new work..")
+	return quiesceSubsystems(bot.workSubsystems())
+}
+
+// quiesceSubsystems quiesces every subsystem implementing quiescer, all are
+// attempted and the errors are returned together
+func quiesceSubsystems(subsystems []namedSubsystem) error {
+	var errs common.Errors
+	for i := range subsystems {
+		q, ok := subsystems[i].subsystem.(quiescer)
+		if !ok {
+			continue
+		}
+		if err := q.Quiesce(); err != nil {
+			gctlog.Errorf(gctlog.Global, "%s unable to quiesce. Error: %v\n", subsystems[i].name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", subsystems[i].name, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// workSubsystems returns the subsystems which take on new work while
+// running, they are quiesced on shutdown and receive runtime settings
+func (bot *Engine) workSubsystems() []namedSubsystem {
+	return []namedSubsystem{
+		{"orders", bot.OrderManager},
+		{"exchange_syncer", bot.currencyPairSyncer},
+		{"websocket_routine", bot.websocketRoutineManager},
+	}
+}
+
 // Stop correctly shuts down engine saving configuration files
 func (bot *Engine) Stop() {
 	newEngineMutex.Lock()
@@ -496,8 +650,12 @@ func (bot *Engine) Stop() {
 		gctlog.Errorf(gctlog.Global, "ExchangeSettings storage system. Error: %v", err)
 	}
 
+	// A read only config saves its runtime changes to the state file
 	if !bot.Settings.EnableDryRun {
+		bot.configMtx.Lock()
+		bot.persistSubLoggerLevels()
 		err := bot.Config.SaveConfigToFile(bot.Settings.ConfigFile)
+		bot.configMtx.Unlock()
 		if err != nil {
 			gctlog.Errorln(gctlog.Global, "Unable to save config.")
 		} else {
@@ -505,11 +663,364 @@ func (bot *Engine) Stop() {
 		}
 	}
 
+	if bot.stopClockJumps != nil {
+		bot.stopClockJumps()
+	}
+	// Cancel outstanding HTTP requests so services blocked on them can exit
+	common.CancelAllRequests()
 	// Wait for services to gracefully shutdown
 	bot.ServicesWG.Wait()
 	if err := gctlog.CloseLogger(); err != nil {
-		log.Printf("Failed to close logger. Error: %v\n", err)
+		gctlog.Errorf(gctlog.Global, "Failed to close logger. Error: %v\n", err)
+	}
+}
+
+// Summary returns the overview of the running bot shown at startup
+func (bot *Engine) Summary() StartupSummary {
+	return bot.summary(len(bot.Config.Exchanges), bot.Config.CountEnabledExchanges())
+}
+
+// summary returns the startup overview given the number of configured and
+// enabled exchanges, every exchange is loaded when all are enabled
+func (bot *Engine) summary(available, enabled int) StartupSummary {
+	if bot.Settings.EnableAllExchanges {
+		enabled = available
+	}
+	return StartupSummary{
+		BotName:            bot.Config.Name,
+		StartedAt:          bot.uptime,
+		DataDir:            bot.Settings.DataDir,
+		LogFile:            bot.logFilePath(),
+		GoMaxProcs:         runtime.GOMAXPROCS(-1),
+		LogicalProcessors:  runtime.NumCPU(),
+		AvailableExchanges: available,
+		EnabledExchanges:   enabled,
+		DryRun:             bot.Settings.EnableDryRun,
+	}
+}
+
+// PrintStartupBanner logs the human readable overview of the running bot
+func (bot *Engine) PrintStartupBanner() {
+	printStartupBanner(gctlog.Global, bot.Summary())
+}
+
+// printStartupBanner logs the human readable startup overview to sl
+func printStartupBanner(sl *gctlog.SubLogger, s StartupSummary) {
+	gctlog.Debugf(sl, "Bot '%s' started.\n", s.BotName)
+	gctlog.Debugf(sl, "Using data dir: %s\n", s.DataDir)
+	if s.LogFile != "" {
+		gctlog.Debugf(sl, "Using log file: %s\n", s.LogFile)
+	}
+	gctlog.Debugf(sl,
+		"Using %d out of %d logical processors for runtime performance\n",
+		s.GoMaxProcs, s.LogicalProcessors)
+
+	gctlog.Debugln(sl, "EXCHANGE COVERAGE")
+	gctlog.Debugf(sl, "\t Available Exchanges: %d. Enabled Exchanges: %d.\n",
+		s.AvailableExchanges, s.EnabledExchanges)
+}
+
+// logStartupFacts logs the startup overview to sl as single line key=value
+// entries, one per fact, for structured log pipelines
+func logStartupFacts(sl *gctlog.SubLogger, s StartupSummary) {
+	gctlog.Infof(sl, "startup bot=%q dry_run=%t\n", s.BotName, s.DryRun)
+	gctlog.Infof(sl, "startup data_dir=%q\n", s.DataDir)
+	if s.LogFile != "" {
+		gctlog.Infof(sl, "startup log_file=%q\n", s.LogFile)
+	}
+	gctlog.Infof(sl, "startup gomaxprocs=%d logical_processors=%d\n",
+		s.GoMaxProcs, s.LogicalProcessors)
+	gctlog.Infof(sl, "startup exchanges_available=%d exchanges_enabled=%d\n",
+		s.AvailableExchanges, s.EnabledExchanges)
+}
+
+// logFilePath returns the path of the log file if file logging is in use
+func (bot *Engine) logFilePath() string {
+	if !*bot.Config.Logging.Enabled ||
+		!strings.Contains(bot.Config.Logging.Output, "file") {
+		return ""
+	}
+	return filepath.Join(gctlog.LogPath, bot.Config.Logging.LoggerFileConfig.FileName)
+}
+
+// ReloadLogging applies the logging section of the supplied config at
+// runtime. All other fields of newCfg are ignored as they require a restart.
+// Sub loggers that changed are reconfigured in place, while changes to the
+// global logger settings or the log file reopen the outputs for every logger.
+// The engine's config is only updated once the new outputs are in use, on
+// failure the previous logging config is restored.
+func (bot *Engine) ReloadLogging(newCfg *config.Config) error {
+	if bot == nil {
+		return errors.New("engine instance is nil")
+	}
+	if newCfg == nil {
+		return errNilConfig
+	}
+	if err := gctlog.ValidateConfig(&newCfg.Logging); err != nil {
+		return fmt.Errorf("cannot reload logging config: %w", err)
+	}
+
+	bot.configMtx.Lock()
+	defer bot.configMtx.Unlock()
+
+	oldCfg := bot.Config.Logging
+	newLogging := newCfg.Logging
+	if globalLoggingChanged(&oldCfg, &newLogging) {
+		if err := applyGlobalLogging(&newLogging); err != nil {
+			if rollbackErr := applyGlobalLogging(&oldCfg); rollbackErr != nil {
+				return fmt.Errorf("%w, restoring previous logging config failed: %v", err, rollbackErr)
+			}
+			bot.setGlobalLogConfig(&oldCfg)
+			return err
+		}
+		bot.setGlobalLogConfig(&newLogging)
+		return nil
+	}
+
+	changed := changedSubLoggers(&oldCfg, &newLogging)
+	if len(changed) == 0 {
+		bot.setGlobalLogConfig(&newLogging)
+		return nil
+	}
+	gctlog.Infof(gctlog.Global, "Reloading %d sub logger(s).\n", len(changed))
+	if _, err := gctlog.SetupSubLoggers(changed); err != nil {
+		// Sub loggers applied before the failure are put back
+		if _, rollbackErr := gctlog.SetupSubLoggers(changedSubLoggers(&newLogging, &oldCfg)); rollbackErr != nil {
+			return fmt.Errorf("%w, restoring previous sub loggers failed: %v", err, rollbackErr)
+		}
+		return err
+	}
+	bot.setGlobalLogConfig(&newLogging)
+	return nil
+}
+
+// applyGlobalLogging reopens the outputs of every logger using cfg, file
+// logging readiness is recomputed as the log file settings may have changed
+func applyGlobalLogging(cfg *gctlog.Config) error {
+	gctlog.RWM.Lock()
+	gctlog.GlobalLogConfig = cfg
+	gctlog.FileLoggingConfiguredCorrectly = fileLoggingReady(cfg)
+	gctlog.RWM.Unlock()
+
+	// SetupGlobalLogger replaces the log file, close the current one so
+	// the handle isn't leaked and a renamed file is opened on next write
+	if err := gctlog.CloseLogger(); err != nil {
+		return fmt.Errorf("cannot close log file: %w", err)
+	}
+	if err := gctlog.SetupGlobalLogger(); err != nil {
+		return fmt.Errorf("cannot setup global logger: %w", err)
+	}
+	if _, err := gctlog.SetupSubLoggers(cfg.SubLoggers); err != nil {
+		return fmt.Errorf("cannot setup sub loggers: %w", err)
+	}
+	return nil
+}
+
+// setGlobalLogConfig stores the applied logging config in the engine's
+// config and points the logger at it. configMtx must be held
+func (bot *Engine) setGlobalLogConfig(cfg *gctlog.Config) {
+	gctlog.RWM.Lock()
+	bot.Config.Logging = *cfg
+	gctlog.GlobalLogConfig = &bot.Config.Logging
+	gctlog.RWM.Unlock()
+}
+
+// fileLoggingReady returns whether the log file can be written with the
+// supplied logging config
+func fileLoggingReady(cfg *gctlog.Config) bool {
+	if cfg.Enabled == nil || !*cfg.Enabled ||
+		cfg.LoggerFileConfig == nil ||
+		cfg.LoggerFileConfig.FileName == "" ||
+		gctlog.LogPath == "" {
+		return false
+	}
+	return common.CreateDir(gctlog.LogPath) == nil
+}
+
+// GetSubLoggerLevels returns the current levels of all registered sub loggers
+func (bot *Engine) GetSubLoggerLevels() map[string]gctlog.Levels {
+	return gctlog.SubLoggerLevels()
+}
+
+// SetSubLoggerLevels sets the levels of every sub logger matching the glob
+// pattern. A positive revertAfter restores the previous levels once it
+// elapses. When persist is set the change is written into the config's sub
+// logger section and, outside of dry run mode, saved to the config file or
+// to the state file when the config is read only.
+func (bot *Engine) SetSubLoggerLevels(pattern, level string, revertAfter time.Duration, persist bool) (map[string]gctlog.Levels, error) {
+	if bot == nil {
+		return nil, errors.New("engine instance is nil")
+	}
+	if persist && revertAfter > 0 {
+		return nil, errPersistTemporaryLogLevel
+	}
+	changed, err := gctlog.SetLevelPattern(pattern, level, revertAfter)
+	if err != nil {
+		return nil, err
+	}
+	for name, levels := range changed {
+		gctlog.Infof(gctlog.Global, "Sub logger %s levels set to %q.\n", name, levels)
+	}
+	if !persist {
+		return changed, nil
+	}
+
+	bot.configMtx.Lock()
+	defer bot.configMtx.Unlock()
+	for name, levels := range changed {
+		bot.setConfigSubLoggerLevel(name, levels.String())
+	}
+	if bot.Settings.EnableDryRun {
+		return changed, nil
+	}
+	return changed, bot.Config.SaveConfigToFile(bot.Settings.ConfigFile)
+}
+
+// setConfigSubLoggerLevel updates the level of a sub logger in the config,
+// adding an entry using the global output if there isn't one. configMtx must
+// be held
+func (bot *Engine) setConfigSubLoggerLevel(name, level string) {
+	subLoggers := bot.Config.Logging.SubLoggers
+	for x := range subLoggers {
+		if strings.EqualFold(subLoggers[x].Name, name) {
+			subLoggers[x].Level = level
+			return
+		}
+	}
+	bot.Config.Logging.SubLoggers = append(subLoggers, gctlog.SubLoggerConfig{
+		Name:   name,
+		Level:  level,
+		Output: bot.Config.Logging.Output,
+	})
+}
+
+// registerSensitiveValues registers loaded credentials with the logger so
+// they are redacted from verbose and wrapper log output
+func (bot *Engine) registerSensitiveValues() {
+	gctlog.RegisterSensitiveValue(bot.Config.Database.Password)
+	for x := range bot.Config.Database.ReadReplicas {
+		gctlog.RegisterSensitiveValue(bot.Config.Database.ReadReplicas[x].Password)
+	}
+}
+
+// persistSubLoggerLevels writes sub logger levels changed at runtime into the
+// config so they survive a restart, temporary changes pending a revert are
+// not persisted and levels which match the config are left untouched.
+// configMtx must be held
+func (bot *Engine) persistSubLoggerLevels() {
+	for name, levels := range gctlog.ExportLevels() {
+		configured := bot.Config.Logging.Level
+		for x := range bot.Config.Logging.SubLoggers {
+			if strings.EqualFold(bot.Config.Logging.SubLoggers[x].Name, name) {
+				configured = bot.Config.Logging.SubLoggers[x].Level
+				break
+			}
+		}
+		if sameLevels(configured, levels.String()) {
+			continue
+		}
+		level := levels.String()
+		if level == "" {
+			level = gctlog.LevelNone
+		}
+		bot.setConfigSubLoggerLevel(name, level)
+	}
+}
+
+// sameLevels returns whether two pipe separated level strings enable the
+// same levels regardless of order
+func sameLevels(a, b string) bool {
+	split := func(level string) map[string]bool {
+		enabled := make(map[string]bool)
+		for _, l := range strings.Split(level, "|") {
+			if l != "" && l != gctlog.LevelNone {
+				enabled[l] = true
+			}
+		}
+		return enabled
+	}
+	enabledA, enabledB := split(a), split(b)
+	if len(enabledA) != len(enabledB) {
+		return false
+	}
+	for l := range enabledA {
+		if !enabledB[l] {
+			return false
+		}
+	}
+	return true
+}
+
+// globalLoggingChanged returns whether any setting shared by all loggers
+// differs between the two logging configs. Unset flags are compared as their
+// default values
+func globalLoggingChanged(oldCfg, newCfg *gctlog.Config) bool {
+	if boolOrDefault(oldCfg.Enabled, true) != boolOrDefault(newCfg.Enabled, true) ||
+		oldCfg.SubLoggerConfig != newCfg.SubLoggerConfig {
+		return true
+	}
+
+	oldAdv, newAdv := oldCfg.AdvancedSettings, newCfg.AdvancedSettings
+	if boolOrDefault(oldAdv.ShowLogSystemName, false) != boolOrDefault(newAdv.ShowLogSystemName, false) ||
+		oldAdv.Spacer != newAdv.Spacer ||
+		oldAdv.TimeStampFormat != newAdv.TimeStampFormat ||
+		oldAdv.Headers != newAdv.Headers ||
+		oldAdv.MaxLineBytes != newAdv.MaxLineBytes {
+		return true
+	}
+
+	oldFile, newFile := oldCfg.LoggerFileConfig, newCfg.LoggerFileConfig
+	if (oldFile == nil) != (newFile == nil) {
+		return true
+	}
+	if oldFile == nil {
+		return false
+	}
+	return oldFile.FileName != newFile.FileName ||
+		oldFile.MaxSize != newFile.MaxSize ||
+		oldFile.BufferSize != newFile.BufferSize ||
+		oldFile.FlushInterval != newFile.FlushInterval ||
+		boolOrDefault(oldFile.Rotate, false) != boolOrDefault(newFile.Rotate, false)
+}
+
+// boolOrDefault returns the value of an optional config flag, or def when it
+// is unset
+func boolOrDefault(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
+// changedSubLoggers returns the sub logger configs which need to be applied
+// to move from the old to the new logging config. Sub loggers which are no
+// longer configured fall back to the global level and output.
+func changedSubLoggers(oldCfg, newCfg *gctlog.Config) []gctlog.SubLoggerConfig {
+	previous := make(map[string]gctlog.SubLoggerConfig, len(oldCfg.SubLoggers))
+	for x := range oldCfg.SubLoggers {
+		previous[strings.ToUpper(oldCfg.SubLoggers[x].Name)] = oldCfg.SubLoggers[x]
+	}
+
+	var changed []gctlog.SubLoggerConfig
+	for x := range newCfg.SubLoggers {
+		name := strings.ToUpper(newCfg.SubLoggers[x].Name)
+		prev, ok := previous[name]
+		delete(previous, name)
+		prev.Name = newCfg.SubLoggers[x].Name
+		if ok && prev == newCfg.SubLoggers[x] {
+			continue
+		}
+		changed = append(changed, newCfg.SubLoggers[x])
+	}
+	for name := range previous {
+		changed = append(changed, gctlog.SubLoggerConfig{
+			Name:   name,
+			Level:  newCfg.Level,
+			Output: newCfg.Output,
+			Filter: newCfg.Filter,
+		})
 	}
+	return changed
 }
 
 // FlagSet defines set flags from command line args for comparison methods
@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zhiwei-w-luo/gotradebot/config"
+	gctlog "github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+// TestReloadLogging is not run in parallel as it repoints the global logger
+// config and log path
+func TestReloadLogging(t *testing.T) {
+	prevConfig := gctlog.GlobalLogConfig
+	prevFileLogging := gctlog.FileLoggingConfiguredCorrectly
+	prevLogPath := gctlog.LogPath
+	defer func() {
+		gctlog.RWM.Lock()
+		gctlog.GlobalLogConfig = prevConfig
+		gctlog.FileLoggingConfiguredCorrectly = prevFileLogging
+		gctlog.LogPath = prevLogPath
+		gctlog.RWM.Unlock()
+	}()
+
+	var err error
+	sl, ok := gctlog.GetSubLogger("reloadtest")
+	if !ok {
+		if sl, err = gctlog.NewSubLogger("reloadtest"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bot := &Engine{Config: &config.Config{Logging: *gctlog.GenDefaultSettings()}}
+
+	if err = bot.ReloadLogging(nil); !errors.Is(err, errNilConfig) {
+		t.Fatalf("received: %v but expected: %v", err, errNilConfig)
+	}
+
+	newCfg := &config.Config{Logging: *gctlog.GenDefaultSettings()}
+	newCfg.Logging.SubLoggers = []gctlog.SubLoggerConfig{
+		{Name: "reloadtest", Level: "VERBOSE", Output: "console"},
+	}
+	if err = bot.ReloadLogging(newCfg); err == nil {
+		t.Fatal("expected an invalid level to be rejected")
+	}
+	if len(bot.Config.Logging.SubLoggers) != 0 {
+		t.Fatal("expected a rejected config to leave the engine config untouched")
+	}
+
+	// A level change applies to the running sub logger without a restart
+	newCfg.Logging.SubLoggers[0].Level = "ERROR"
+	if err = bot.ReloadLogging(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	if levels := sl.GetLevels(); levels.Debug || levels.Info || levels.Warn || !levels.Error {
+		t.Fatalf("received: %v but expected only error enabled", levels)
+	}
+	if len(bot.Config.Logging.SubLoggers) != 1 {
+		t.Fatal("expected the applied sub logger to be stored in the engine config")
+	}
+	if gctlog.GlobalLogConfig != &bot.Config.Logging {
+		t.Fatal("expected the global logger to use the engine logging config")
+	}
+
+	// Enabling file output reopens every logger and recomputes whether the
+	// log file can be written
+	gctlog.LogPath = t.TempDir()
+	newCfg.Logging.Output = "console|file"
+	if err = bot.ReloadLogging(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	if !gctlog.FileLoggingConfiguredCorrectly {
+		t.Fatal("expected file logging to be configured")
+	}
+	if levels := sl.GetLevels(); levels.Debug || !levels.Error {
+		t.Fatalf("received: %v but expected sub logger level to be kept", levels)
+	}
+
+	newCfg.Logging.Output = "console"
+	newCfg.Logging.LoggerFileConfig = nil
+	if err = bot.ReloadLogging(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	if gctlog.FileLoggingConfiguredCorrectly {
+		t.Fatal("expected file logging to be unconfigured")
+	}
+	if err = gctlog.CloseLogger(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGlobalLoggingChanged(t *testing.T) {
+	t.Parallel()
+	unset := gctlog.Config{}
+	if globalLoggingChanged(&unset, &gctlog.Config{}) {
+		t.Error("expected unset configs to be unchanged")
+	}
+
+	// Unset flags compare as their defaults rather than panicking
+	defaults := gctlog.GenDefaultSettings()
+	defaults.SubLoggerConfig = gctlog.SubLoggerConfig{}
+	defaults.LoggerFileConfig = nil
+	defaults.AdvancedSettings = unset.AdvancedSettings
+	if globalLoggingChanged(&unset, defaults) {
+		t.Error("expected an unset enabled flag to match the enabled default")
+	}
+	disabled := false
+	defaults.Enabled = &disabled
+	if !globalLoggingChanged(&unset, defaults) {
+		t.Error("expected disabling logging to be a change")
+	}
+}
+
+// testSubLogger returns a sub logger with every level enabled which writes to
+// w, registering it on first use
+func testSubLogger(t *testing.T, name string, w *bytes.Buffer) *gctlog.SubLogger {
+	t.Helper()
+	sl, ok := gctlog.GetSubLogger(name)
+	if !ok {
+		var err error
+		if sl, err = gctlog.NewSubLogger(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sl.SetOutput(w)
+	sl.SetLevels(gctlog.Levels{Info: true, Debug: true, Warn: true, Error: true})
+	return sl
+}
+
+func TestStartupOutput(t *testing.T) {
+	t.Parallel()
+	s := StartupSummary{
+		BotName:            "test bot",
+		DataDir:            "/data",
+		GoMaxProcs:         2,
+		LogicalProcessors:  4,
+		AvailableExchanges: 3,
+		EnabledExchanges:   1,
+		DryRun:             true,
+	}
+
+	var quiet bytes.Buffer
+	logStartupFacts(testSubLogger(t, "startupquiet", &quiet), s)
+	lines := strings.Split(strings.TrimSuffix(quiet.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("received: %d lines but expected: %d\n%s", len(lines), 4, quiet.String())
+	}
+	for x := range lines {
+		if !strings.Contains(lines[x], "startup ") {
+			t.Errorf("line %q is not a startup fact", lines[x])
+		}
+	}
+	if strings.Contains(quiet.String(), "EXCHANGE COVERAGE") {
+		t.Error("expected no banner output in quiet mode")
+	}
+	if !strings.Contains(quiet.String(), "exchanges_available=3 exchanges_enabled=1") {
+		t.Errorf("expected the exchange counts to be logged, received:\n%s", quiet.String())
+	}
+
+	var banner bytes.Buffer
+	printStartupBanner(testSubLogger(t, "startupbanner", &banner), s)
+	if !strings.Contains(banner.String(), "EXCHANGE COVERAGE") {
+		t.Errorf("expected the banner to be printed, received:\n%s", banner.String())
+	}
+}
+
+func TestSummary(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{
+		Config: &config.Config{
+			Name:    "summary",
+			Logging: *gctlog.GenDefaultSettings(),
+		},
+		Settings: Settings{DataDir: "/data", EnableDryRun: true},
+	}
+
+	s := bot.summary(3, 2)
+	if s.BotName != "summary" || s.DataDir != "/data" || !s.DryRun {
+		t.Errorf("received: %+v but expected the bot details", s)
+	}
+	if s.AvailableExchanges != 3 {
+		t.Errorf("received: %d but expected: %d", s.AvailableExchanges, 3)
+	}
+	if s.EnabledExchanges != 2 {
+		t.Errorf("received: %d but expected: %d", s.EnabledExchanges, 2)
+	}
+	if s.LogFile != "" {
+		t.Errorf("received: %q but expected no log file for console output", s.LogFile)
+	}
+
+	// Every exchange is loaded when all exchanges are enabled
+	bot.Settings.EnableAllExchanges = true
+	if s = bot.summary(3, 2); s.EnabledExchanges != 3 {
+		t.Errorf("received: %d but expected: %d", s.EnabledExchanges, 3)
+	}
+}
+
+var (
+	errQuiesceOrders = errors.New("orders still draining")
+	errQuiesceSyncer = errors.New("syncer still draining")
+)
+
+// countingQuiescer is a stub subsystem which records its Quiesce calls
+type countingQuiescer struct {
+	calls int32
+	err   error
+}
+
+func (c *countingQuiescer) Quiesce() error {
+	atomic.AddInt32(&c.calls, 1)
+	return c.err
+}
+
+func TestQuiesceSubsystems(t *testing.T) {
+	t.Parallel()
+	orders, syncer := &countingQuiescer{}, &countingQuiescer{}
+	if err := quiesceSubsystems([]namedSubsystem{
+		{"orders", orders},
+		{"no_quiesce", struct{}{}},
+		{"syncer", syncer},
+	}); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	for _, q := range []*countingQuiescer{orders, syncer} {
+		if calls := atomic.LoadInt32(&q.calls); calls != 1 {
+			t.Fatalf("received: %d Quiesce calls but expected: %d", calls, 1)
+		}
+	}
+
+	// Every subsystem is attempted and the failures are returned together
+	orders = &countingQuiescer{err: errQuiesceOrders}
+	syncer = &countingQuiescer{err: errQuiesceSyncer}
+	websocket := &countingQuiescer{}
+	err := quiesceSubsystems([]namedSubsystem{
+		{"orders", orders},
+		{"syncer", syncer},
+		{"websocket", websocket},
+	})
+	expected := "orders: " + errQuiesceOrders.Error() + ", syncer: " + errQuiesceSyncer.Error()
+	if err == nil || err.Error() != expected {
+		t.Fatalf("received: %v but expected: %s", err, expected)
+	}
+	for _, q := range []*countingQuiescer{orders, syncer, websocket} {
+		if calls := atomic.LoadInt32(&q.calls); calls != 1 {
+			t.Fatalf("received: %d Quiesce calls but expected: %d", calls, 1)
+		}
+	}
+}
+
+func TestEngineQuiesce(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{}
+	subsystems := bot.workSubsystems()
+	names := make([]string, len(subsystems))
+	for x := range subsystems {
+		names[x] = subsystems[x].name
+	}
+	if strings.Join(names, ",") != "orders,exchange_syncer,websocket_routine" {
+		t.Fatalf("received: %v but expected the order, sync and websocket routine managers", names)
+	}
+	// Managers which are not set up are skipped or safely quiesced
+	if err := bot.Quiesce(); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+}
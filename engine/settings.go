@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/common"
+	"github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+var (
+	errSettingsRequireRestart = errors.New("settings cannot be changed at runtime, a restart is required")
+	errInvalidRuntimeSetting  = errors.New("invalid runtime setting")
+	errIncompatibleSettings   = errors.New("incompatible settings")
+)
+
+// settingDependency is an entry in the subsystem dependency matrix. When
+// setting is enabled and requires is not, the dependency is enabled with a
+// warning if enable is set, otherwise validation fails
+type settingDependency struct {
+	setting, requires string
+	enabled           func(s *Settings) bool
+	satisfied         func(s *Settings) bool
+	enable            func(s *Settings)
+}
+
+// settingDependencies is the subsystem dependency matrix:
+//
+//	Setting                     Requires                   Resolution
+//	EnableWebsocketRoutine      EnableExchangeSyncManager  auto enabled
+//	EnableEventManager          EnableCommsRelayer         auto enabled
+//	EnableGRPCProxy             EnableGRPC                 error
+//	EnableDataHistoryManager    EnableDatabaseManager      error
+//	EnableExchangeSyncManager   a ticker, orderbook or     error
+//	                            trade syncing setting
+//
+// Settings which start listeners or need further config are never enabled
+// automatically
+var settingDependencies = []settingDependency{
+	{
+		setting:   "EnableWebsocketRoutine",
+		requires:  "EnableExchangeSyncManager",
+		enabled:   func(s *Settings) bool { return s.EnableWebsocketRoutine },
+		satisfied: func(s *Settings) bool { return s.EnableExchangeSyncManager },
+		enable:    func(s *Settings) { s.EnableExchangeSyncManager = true },
+	},
+	{
+		setting:   "EnableEventManager",
+		requires:  "EnableCommsRelayer",
+		enabled:   func(s *Settings) bool { return s.EnableEventManager },
+		satisfied: func(s *Settings) bool { return s.EnableCommsRelayer },
+		enable:    func(s *Settings) { s.EnableCommsRelayer = true },
+	},
+	{
+		setting:   "EnableGRPCProxy",
+		requires:  "EnableGRPC",
+		enabled:   func(s *Settings) bool { return s.EnableGRPCProxy },
+		satisfied: func(s *Settings) bool { return s.EnableGRPC },
+	},
+	{
+		setting:   "EnableDataHistoryManager",
+		requires:  "EnableDatabaseManager",
+		enabled:   func(s *Settings) bool { return s.EnableDataHistoryManager },
+		satisfied: func(s *Settings) bool { return s.EnableDatabaseManager },
+	},
+	{
+		setting:  "EnableExchangeSyncManager",
+		requires: "EnableTickerSyncing, EnableOrderbookSyncing or EnableTradeSyncing",
+		enabled:  func(s *Settings) bool { return s.EnableExchangeSyncManager },
+		satisfied: func(s *Settings) bool {
+			return s.EnableTickerSyncing || s.EnableOrderbookSyncing || s.EnableTradeSyncing
+		},
+	},
+}
+
+// Validate checks the subsystem settings against the dependency matrix,
+// enabling safe dependencies with a warning and returning every combination
+// which cannot be resolved. Dependencies are resolved in matrix order, so an
+// automatically enabled subsystem is itself validated by later entries
+func (s *Settings) Validate() error {
+	var problems []string
+	for x := range settingDependencies {
+		d := &settingDependencies[x]
+		if !d.enabled(s) || d.satisfied(s) {
+			continue
+		}
+		if d.enable != nil {
+			d.enable(s)
+			log.Warnf(log.Global, "%s requires %s, enabling it\n", d.setting, d.requires)
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s requires %s", d.setting, d.requires))
+	}
+	if s.EnableExchangeAutoPairUpdates && s.DisableExchangeAutoPairUpdates {
+		problems = append(problems, "EnableExchangeAutoPairUpdates and DisableExchangeAutoPairUpdates are both set")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errIncompatibleSettings, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// GetSettings returns a copy of the engine's current settings
+func (bot *Engine) GetSettings() Settings {
+	bot.settingsMtx.Lock()
+	defer bot.settingsMtx.Unlock()
+	return bot.Settings
+}
+
+// verboseSetter is implemented by running subsystems whose verbosity can be
+// changed without a restart. As with IsRunning, SetVerbose must be safe to
+// call on a nil receiver
+type verboseSetter interface {
+	SetVerbose(verbose bool)
+}
+
+// syncTuner is implemented by the exchange syncer to change its worker count
+// and timeouts without a restart. It must be safe to call on a nil receiver
+type syncTuner interface {
+	SetSyncTuning(workers int, timeoutREST, timeoutWebsocket time.Duration) error
+}
+
+// ApplySettings applies the settings which are safe to change while running,
+// verbosity, sync timeouts and worker counts, and pushes them into the
+// running subsystems. If s differs from the current settings in any other
+// field nothing is applied and the fields needing a restart are listed in the
+// error
+func (bot *Engine) ApplySettings(s Settings) error {
+	if bot == nil {
+		return errors.New("engine instance is nil")
+	}
+	if s.SyncWorkersCount <= 0 {
+		return fmt.Errorf("%w: sync workers count must be above zero", errInvalidRuntimeSetting)
+	}
+	if s.SyncTimeoutREST <= 0 || s.SyncTimeoutWebsocket <= 0 {
+		return fmt.Errorf("%w: sync timeouts must be above zero", errInvalidRuntimeSetting)
+	}
+
+	// Start and Stop read the settings without settingsMtx, holding the
+	// engine mutex stops them racing with the change
+	newEngineMutex.Lock()
+	defer newEngineMutex.Unlock()
+	bot.settingsMtx.Lock()
+	if changed := restartRequiredChanges(&bot.Settings, &s); len(changed) > 0 {
+		bot.settingsMtx.Unlock()
+		return fmt.Errorf("%w: %s", errSettingsRequireRestart, strings.Join(changed, ", "))
+	}
+	bot.Settings.Verbose = s.Verbose
+	bot.Settings.EnableExchangeVerbose = s.EnableExchangeVerbose
+	bot.Settings.SyncTimeoutREST = s.SyncTimeoutREST
+	bot.Settings.SyncTimeoutWebsocket = s.SyncTimeoutWebsocket
+	bot.Settings.SyncWorkersCount = s.SyncWorkersCount
+	bot.settingsMtx.Unlock()
+
+	subsystems := bot.workSubsystems()
+	var errs common.Errors
+	for i := range subsystems {
+		if v, ok := subsystems[i].subsystem.(verboseSetter); ok {
+			v.SetVerbose(s.Verbose)
+		}
+		t, ok := subsystems[i].subsystem.(syncTuner)
+		if !ok {
+			continue
+		}
+		if err := t.SetSyncTuning(s.SyncWorkersCount, s.SyncTimeoutREST, s.SyncTimeoutWebsocket); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", subsystems[i].name, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// restartRequiredChanges returns the names of fields which differ between
+// current and proposed, ignoring those ApplySettings can change
+func restartRequiredChanges(current, proposed *Settings) []string {
+	check := *proposed
+	check.Verbose = current.Verbose
+	check.EnableExchangeVerbose = current.EnableExchangeVerbose
+	check.SyncTimeoutREST = current.SyncTimeoutREST
+	check.SyncTimeoutWebsocket = current.SyncTimeoutWebsocket
+	check.SyncWorkersCount = current.SyncWorkersCount
+	if check == *current {
+		return nil
+	}
+
+	var changed []string
+	cur, prop := reflect.ValueOf(*current), reflect.ValueOf(check)
+	for x := 0; x < cur.NumField(); x++ {
+		if cur.Field(x).Interface() != prop.Field(x).Interface() {
+			changed = append(changed, cur.Type().Field(x).Name)
+		}
+	}
+	return changed
+}
+
+// EffectiveConfig returns a redacted copy of the config with the overrides
+// applied from settings and command line flags at startup, so it reflects
+// what is running rather than what is on disk. Dry run mode, which the
+// -datadir flag forces on, is reported alongside the config
+func (bot *Engine) EffectiveConfig() RunningConfig {
+	cfg := bot.Config.Redacted()
+	s := bot.GetSettings()
+	if s.DataDir != "" {
+		cfg.DataDirectory = s.DataDir
+	}
+	if s.GlobalHTTPTimeout > 0 {
+		cfg.GlobalHTTPTimeout = s.GlobalHTTPTimeout
+	}
+	if !s.EnableDatabaseManager {
+		cfg.Database.Enabled = false
+	}
+	return RunningConfig{Config: cfg, DryRun: s.EnableDryRun}
+}
@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/config"
+	gctlog "github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+// writeTestConfig writes a plaintext config to a temp dir and returns its path
+func writeTestConfig(t *testing.T, c *config.Config) string {
+	t.Helper()
+	if c.Logging.Enabled == nil {
+		c.Logging = *gctlog.GenDefaultSettings()
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), config.File)
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplySettings(t *testing.T) {
+	t.Parallel()
+	current := Settings{
+		EnableOrderManager:   true,
+		SyncWorkersCount:     15,
+		SyncTimeoutREST:      time.Second * 15,
+		SyncTimeoutWebsocket: time.Minute,
+	}
+	bot := &Engine{Settings: current}
+
+	s := bot.GetSettings()
+	s.SyncWorkersCount = 0
+	if err := bot.ApplySettings(s); !errors.Is(err, errInvalidRuntimeSetting) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidRuntimeSetting)
+	}
+
+	// A restart required field rejects the whole change
+	s = bot.GetSettings()
+	s.Verbose = true
+	s.SyncWorkersCount = 20
+	s.EnableOrderManager = false
+	s.DataDir = "/elsewhere"
+	err := bot.ApplySettings(s)
+	if !errors.Is(err, errSettingsRequireRestart) {
+		t.Fatalf("received: %v but expected: %v", err, errSettingsRequireRestart)
+	}
+	if !strings.Contains(err.Error(), "EnableOrderManager") || !strings.Contains(err.Error(), "DataDir") {
+		t.Errorf("expected the restart required fields to be listed, received: %v", err)
+	}
+	if got := bot.GetSettings(); got != current {
+		t.Fatalf("received: %+v but expected settings to be unchanged: %+v", got, current)
+	}
+
+	s = bot.GetSettings()
+	s.Verbose = true
+	s.SyncWorkersCount = 20
+	s.SyncTimeoutREST = time.Second * 30
+	if err = bot.ApplySettings(s); err != nil {
+		t.Fatal(err)
+	}
+	if got := bot.GetSettings(); got != s {
+		t.Fatalf("received: %+v but expected: %+v", got, s)
+	}
+}
+
+func TestEffectiveConfigDataDirDryRun(t *testing.T) {
+	t.Parallel()
+	path := writeTestConfig(t, &config.Config{
+		Name:          "effective",
+		DataDirectory: "/on/disk",
+		EncryptConfig: -1,
+	})
+
+	settings := &Settings{ConfigFile: path}
+	conf, err := loadConfigWithSettings(settings, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot := &Engine{Config: conf, Settings: *settings}
+	if cfg := bot.EffectiveConfig(); cfg.DryRun {
+		t.Fatal("expected dry run to be disabled without -datadir")
+	}
+
+	dataDir := t.TempDir()
+	settings = &Settings{ConfigFile: path, DataDir: dataDir}
+	conf, err = loadConfigWithSettings(settings, map[string]bool{"datadir": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot = &Engine{Config: conf, Settings: *settings}
+	cfg := bot.EffectiveConfig()
+	if !cfg.DryRun {
+		t.Fatal("expected -datadir to show dry run enabled")
+	}
+	if cfg.DataDirectory != dataDir {
+		t.Fatalf("received: %s but expected: %s", cfg.DataDirectory, dataDir)
+	}
+	// Dry run mode is rendered alongside the config fields
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"dryRun":true`) || !strings.Contains(string(data), `"name":"effective"`) {
+		t.Fatalf("received: %s but expected the config with dry run enabled", data)
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		settings Settings
+		expected error
+		problems []string
+		check    func(s *Settings) bool
+	}{
+		{
+			name:     "no subsystems",
+			settings: Settings{},
+		},
+		{
+			name:     "websocket routine enables the syncer",
+			settings: Settings{EnableWebsocketRoutine: true, EnableTickerSyncing: true},
+			check:    func(s *Settings) bool { return s.EnableExchangeSyncManager },
+		},
+		{
+			name:     "event manager enables comms",
+			settings: Settings{EnableEventManager: true},
+			check:    func(s *Settings) bool { return s.EnableCommsRelayer },
+		},
+		{
+			name:     "grpc proxy without grpc",
+			settings: Settings{EnableGRPCProxy: true},
+			expected: errIncompatibleSettings,
+			problems: []string{"EnableGRPCProxy requires EnableGRPC"},
+		},
+		{
+			name:     "data history without database",
+			settings: Settings{EnableDataHistoryManager: true},
+			expected: errIncompatibleSettings,
+			problems: []string{"EnableDataHistoryManager requires EnableDatabaseManager"},
+		},
+		{
+			name:     "auto enabled syncer without syncing",
+			settings: Settings{EnableWebsocketRoutine: true},
+			expected: errIncompatibleSettings,
+			problems: []string{"EnableExchangeSyncManager requires"},
+		},
+		{
+			name:     "pair updates enabled and disabled",
+			settings: Settings{EnableExchangeAutoPairUpdates: true, DisableExchangeAutoPairUpdates: true},
+			expected: errIncompatibleSettings,
+			problems: []string{"both set"},
+		},
+		{
+			name: "every problem is reported",
+			settings: Settings{
+				EnableGRPCProxy:          true,
+				EnableDataHistoryManager: true,
+			},
+			expected: errIncompatibleSettings,
+			problems: []string{"EnableGRPCProxy", "EnableDataHistoryManager"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.settings.Validate()
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("received: %v but expected: %v", err, tc.expected)
+			}
+			for x := range tc.problems {
+				if !strings.Contains(err.Error(), tc.problems[x]) {
+					t.Errorf("received: %v but expected it to contain: %s", err, tc.problems[x])
+				}
+			}
+			if tc.check != nil && !tc.check(&tc.settings) {
+				t.Errorf("expected the dependency to be enabled, received: %+v", tc.settings)
+			}
+		})
+	}
+}
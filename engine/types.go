@@ -1,8 +1,11 @@
 package engine
 
 import (
+	"errors"
 	"sync"
 	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/config"
 )
 
 // Settings stores engine params
@@ -16,6 +19,7 @@ type Settings struct {
 
 	// Core Settings
 	EnableDryRun                bool
+	ReadOnlyConfig              bool
 	EnableAllExchanges          bool
 	EnableAllPairs              bool
 	EnableCoinmarketcapAnalysis bool
@@ -39,6 +43,14 @@ type Settings struct {
 	EnableCurrencyStateManager  bool
 	EventManagerDelay           time.Duration
 	Verbose                     bool
+	QuietStartup                bool
+
+	// Diagnostics settings
+	EnableDiagnosticsStackDump bool
+
+	// Subsystem start watchdog settings
+	SubsystemStartTimeout    time.Duration
+	ContinueOnSubsystemError bool
 
 	// Exchange syncer settings
 	EnableTickerSyncing    bool
@@ -92,6 +104,33 @@ type Settings struct {
 	WithdrawCacheSize uint64
 }
 
+// namedSubsystem pairs a subsystem with the name used in logs and errors
+type namedSubsystem struct {
+	name      string
+	subsystem interface{}
+}
+
+// RunningConfig is the effective config returned by Engine.EffectiveConfig
+// together with the run time state which is not stored in the config file
+type RunningConfig struct {
+	*config.Config
+	DryRun bool `json:"dryRun"`
+}
+
+// StartupSummary is the overview of the running bot logged at startup, also
+// available for status reporting via Engine.Summary
+type StartupSummary struct {
+	BotName            string    `json:"botName"`
+	StartedAt          time.Time `json:"startedAt"`
+	DataDir            string    `json:"dataDir"`
+	LogFile            string    `json:"logFile,omitempty"`
+	GoMaxProcs         int       `json:"goMaxProcs"`
+	LogicalProcessors  int       `json:"logicalProcessors"`
+	AvailableExchanges int       `json:"availableExchanges"`
+	EnabledExchanges   int       `json:"enabledExchanges"`
+	DryRun             bool      `json:"dryRun"`
+}
+
 const (
 	// MsgStatusOK message to display when status is "OK"
 	MsgStatusOK string = "ok"
@@ -101,10 +140,16 @@ const (
 	MsgStatusError string = "error"
 	grpcName       string = "grpc"
 	grpcProxyName  string = "grpc_proxy"
+
+	defaultSubsystemStartTimeout  = time.Second * 30
+	maxSubsystemStartWarnInterval = time.Minute * 5
+	clockJumpCheckInterval        = time.Second * 5
+	clockJumpThreshold            = time.Second * 10
 )
 
+var errPersistTemporaryLogLevel = errors.New("cannot persist a log level change which reverts")
+
 // newConfigMutex only locks and unlocks on engine creation functions
 // as engine modifies global files, this protects the main bot creation
 // functions from interfering with each other
 var newEngineMutex sync.Mutex
-
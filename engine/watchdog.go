@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/log"
+)
+
+var (
+	errSubsystemStartTimeout = errors.New("subsystem start timed out")
+	errDependencyNotReady    = errors.New("dependency not ready")
+)
+
+// readier is implemented by subsystems which other subsystems depend on, the
+// returned error is nil once the subsystem can be relied upon. As with
+// IsRunning, WaitReady must be safe to call on a nil receiver
+type readier interface {
+	WaitReady(ctx context.Context) error
+}
+
+// AbandonedStart records a subsystem whose start exceeded the start timeout
+// and was abandoned under ContinueOnSubsystemError
+type AbandonedStart struct {
+	Name        string    `json:"name"`
+	AbandonedAt time.Time `json:"abandonedAt"`
+	// FinishedAt is zero while the start is still blocked
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+	StartError string    `json:"startError,omitempty"`
+	// Stopped is set when the start eventually succeeded and the subsystem
+	// was stopped again, as the engine carried on without it
+	Stopped   bool   `json:"stopped"`
+	StopError string `json:"stopError,omitempty"`
+}
+
+// startWithWatchdog runs a subsystem start function and logs a warning every
+// time it exceeds the start timeout, so a setup which blocks is visible in the
+// logs instead of silently stalling Engine.Start. The interval between
+// warnings doubles up to maxSubsystemStartWarnInterval. When
+// ContinueOnSubsystemError is set the subsystem is abandoned after the first
+// timeout and the engine carries on starting the remaining subsystems, should
+// the abandoned start succeed later the subsystem is stopped using stop.
+func (bot *Engine) startWithWatchdog(name string, start, stop func() error) error {
+	timeout := bot.Settings.SubsystemStartTimeout
+	if timeout <= 0 {
+		timeout = defaultSubsystemStartTimeout
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- start()
+	}()
+
+	began := time.Now()
+	warnAfter := timeout
+	timer := time.NewTimer(warnAfter)
+	defer timer.Stop()
+	for {
+		select {
+		case err := <-result:
+			if bot.Settings.QuietStartup {
+				log.Infof(log.Global, "startup subsystem=%q started=%t error=%q\n",
+					name,
+					err == nil,
+					errorString(err))
+			}
+			return err
+		case <-timer.C:
+			log.Errorf(log.Global,
+				"%s start has not returned after %s and may be deadlocked\n",
+				name,
+				time.Since(began).Round(time.Second))
+			if bot.Settings.ContinueOnSubsystemError {
+				bot.abandonStart(name, result, stop)
+				if bot.Settings.QuietStartup {
+					log.Infof(log.Global, "startup subsystem=%q started=false error=%q\n",
+						name,
+						errSubsystemStartTimeout)
+				}
+				return fmt.Errorf("%s %w after %s, continuing without it",
+					name,
+					errSubsystemStartTimeout,
+					timeout)
+			}
+			if warnAfter *= 2; warnAfter > maxSubsystemStartWarnInterval {
+				warnAfter = maxSubsystemStartWarnInterval
+			}
+			timer.Reset(warnAfter)
+		}
+	}
+}
+
+// abandonStart records an abandoned subsystem start and waits on its result
+// in the background. A start which succeeds after the engine moved on is
+// stopped, so the subsystem does not run while reported as failed
+func (bot *Engine) abandonStart(name string, result <-chan error, stop func() error) {
+	bot.abandonedMtx.Lock()
+	idx := len(bot.abandonedStarts)
+	bot.abandonedStarts = append(bot.abandonedStarts, AbandonedStart{
+		Name:        name,
+		AbandonedAt: time.Now(),
+	})
+	bot.abandonedMtx.Unlock()
+
+	go func() {
+		startErr := <-result
+		var stopped bool
+		var stopErr error
+		if startErr == nil && stop != nil {
+			log.Warnf(log.Global, "%s started after it was abandoned, stopping it\n", name)
+			stopErr = stop()
+			stopped = stopErr == nil
+			if stopErr != nil {
+				log.Errorf(log.Global, "%s unable to stop after late start. Error: %v\n", name, stopErr)
+			}
+		}
+
+		bot.abandonedMtx.Lock()
+		defer bot.abandonedMtx.Unlock()
+		a := &bot.abandonedStarts[idx]
+		a.FinishedAt = time.Now()
+		a.StartError = errorString(startErr)
+		a.Stopped = stopped
+		a.StopError = errorString(stopErr)
+	}()
+}
+
+// AbandonedStarts returns the subsystem starts abandoned after exceeding the
+// start timeout and what became of them
+func (bot *Engine) AbandonedStarts() []AbandonedStart {
+	bot.abandonedMtx.Lock()
+	defer bot.abandonedMtx.Unlock()
+	if len(bot.abandonedStarts) == 0 {
+		return nil
+	}
+	starts := make([]AbandonedStart, len(bot.abandonedStarts))
+	copy(starts, bot.abandonedStarts)
+	return starts
+}
+
+// waitForDependency blocks until the dependency of a subsystem reports ready,
+// bounded by the subsystem start timeout. Dependencies which do not implement
+// readier are treated as ready once their Start has returned
+func (bot *Engine) waitForDependency(name, dependencyName string, dependency interface{}) error {
+	r, ok := dependency.(readier)
+	if !ok {
+		return nil
+	}
+	timeout := bot.Settings.SubsystemStartTimeout
+	if timeout <= 0 {
+		timeout = defaultSubsystemStartTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := r.WaitReady(ctx); err != nil {
+		return fmt.Errorf("%s %w: %s: %v", name, errDependencyNotReady, dependencyName, err)
+	}
+	return nil
+}
+
+// errorString returns the error message or an empty string for a nil error
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
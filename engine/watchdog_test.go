@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingSubsystem is a stub subsystem whose Start blocks until released
+type blockingSubsystem struct {
+	release  chan struct{}
+	startErr error
+	running  int32
+	stops    int32
+}
+
+func newBlockingSubsystem(startErr error) *blockingSubsystem {
+	return &blockingSubsystem{release: make(chan struct{}), startErr: startErr}
+}
+
+func (b *blockingSubsystem) Start() error {
+	<-b.release
+	if b.startErr != nil {
+		return b.startErr
+	}
+	atomic.StoreInt32(&b.running, 1)
+	return nil
+}
+
+func (b *blockingSubsystem) Stop() error {
+	atomic.AddInt32(&b.stops, 1)
+	atomic.StoreInt32(&b.running, 0)
+	return nil
+}
+
+// waitForAbandonedStart polls until the abandoned start has finished
+func waitForAbandonedStart(t *testing.T, bot *Engine) AbandonedStart {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		starts := bot.AbandonedStarts()
+		if len(starts) != 1 {
+			t.Fatalf("received: %d abandoned starts but expected: 1", len(starts))
+		}
+		if !starts[0].FinishedAt.IsZero() {
+			return starts[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned start did not finish")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestStartWithWatchdogAbandonedLateSuccess(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{Settings: Settings{
+		SubsystemStartTimeout:    time.Millisecond * 20,
+		ContinueOnSubsystemError: true,
+	}}
+	s := newBlockingSubsystem(nil)
+	err := bot.startWithWatchdog("blocking", s.Start, s.Stop)
+	if !errors.Is(err, errSubsystemStartTimeout) {
+		t.Fatalf("received: %v but expected: %v", err, errSubsystemStartTimeout)
+	}
+	starts := bot.AbandonedStarts()
+	if len(starts) != 1 || starts[0].Name != "blocking" || !starts[0].FinishedAt.IsZero() {
+		t.Fatalf("received: %+v but expected a pending abandoned start", starts)
+	}
+
+	close(s.release)
+	a := waitForAbandonedStart(t, bot)
+	if !a.Stopped || a.StartError != "" || a.StopError != "" {
+		t.Fatalf("received: %+v but expected the late start to be stopped", a)
+	}
+	if atomic.LoadInt32(&s.running) != 0 || atomic.LoadInt32(&s.stops) != 1 {
+		t.Fatal("expected the subsystem to be stopped once after its late start")
+	}
+}
+
+func TestStartWithWatchdogAbandonedLateFailure(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{Settings: Settings{
+		SubsystemStartTimeout:    time.Millisecond * 20,
+		ContinueOnSubsystemError: true,
+	}}
+	errStart := errors.New("start failed")
+	s := newBlockingSubsystem(errStart)
+	err := bot.startWithWatchdog("blocking", s.Start, s.Stop)
+	if !errors.Is(err, errSubsystemStartTimeout) {
+		t.Fatalf("received: %v but expected: %v", err, errSubsystemStartTimeout)
+	}
+
+	close(s.release)
+	a := waitForAbandonedStart(t, bot)
+	if a.Stopped || a.StartError != errStart.Error() {
+		t.Fatalf("received: %+v but expected the start error to be recorded", a)
+	}
+	if atomic.LoadInt32(&s.stops) != 0 {
+		t.Fatal("expected a failed start not to be stopped")
+	}
+}
+
+func TestStartWithWatchdogWaitsWithoutContinue(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{Settings: Settings{SubsystemStartTimeout: time.Millisecond * 10}}
+	s := newBlockingSubsystem(nil)
+	time.AfterFunc(time.Millisecond*50, func() { close(s.release) })
+	if err := bot.startWithWatchdog("blocking", s.Start, s.Stop); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	if atomic.LoadInt32(&s.running) != 1 || atomic.LoadInt32(&s.stops) != 0 {
+		t.Fatal("expected the subsystem to be left running")
+	}
+	if starts := bot.AbandonedStarts(); starts != nil {
+		t.Fatalf("received: %+v but expected no abandoned starts", starts)
+	}
+}
+
+// readyDependency is a stub subsystem which reports ready once started
+type readyDependency struct {
+	ready chan struct{}
+}
+
+func (d *readyDependency) WaitReady(ctx context.Context) error {
+	select {
+	case <-d.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestWaitForDependency(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{Settings: Settings{SubsystemStartTimeout: time.Second * 5}}
+
+	var mtx sync.Mutex
+	var order []string
+	record := func(event string) {
+		mtx.Lock()
+		order = append(order, event)
+		mtx.Unlock()
+	}
+
+	// As with the exchange syncer, the dependency starts in its own routine
+	dependency := &readyDependency{ready: make(chan struct{})}
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		record("dependency ready")
+		close(dependency.ready)
+	}()
+	if err := bot.waitForDependency("dependent", "dependency", dependency); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+	record("dependent started")
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(order) != 2 || order[0] != "dependency ready" || order[1] != "dependent started" {
+		t.Fatalf("received: %v but expected the dependency to be ready first", order)
+	}
+}
+
+func TestWaitForDependencyNotReady(t *testing.T) {
+	t.Parallel()
+	bot := &Engine{Settings: Settings{SubsystemStartTimeout: time.Millisecond * 20}}
+
+	err := bot.waitForDependency("dependent", "dependency", &readyDependency{ready: make(chan struct{})})
+	if !errors.Is(err, errDependencyNotReady) {
+		t.Fatalf("received: %v but expected: %v", err, errDependencyNotReady)
+	}
+
+	// Dependencies without WaitReady are ready once their Start has returned
+	if err = bot.waitForDependency("dependent", "dependency", newBlockingSubsystem(nil)); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+
+	// A database manager which was never started is not ready
+	err = bot.waitForDependency("dependent", DatabaseConnectionManagerName, &DatabaseConnectionManager{})
+	if !errors.Is(err, errDependencyNotReady) {
+		t.Fatalf("received: %v but expected: %v", err, errDependencyNotReady)
+	}
+}
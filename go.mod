@@ -5,4 +5,5 @@ go 1.18
 require (
 	github.com/lib/pq v1.10.6
 	github.com/shopspring/decimal v1.3.1
+	golang.org/x/crypto v0.9.0
 )
@@ -1,16 +1,22 @@
 package log
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"path"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 var (
 	errEmptyLoggerName            = errors.New("cannot have empty logger name")
 	errSubLoggerAlreadyregistered = errors.New("sub logger already registered")
+	errNoSubLoggersMatched        = errors.New("no sub loggers matched")
 )
 
 func newLogger(c *Config) Logger {
@@ -22,13 +28,44 @@ func newLogger(c *Config) Logger {
 		WarnHeader:        c.AdvancedSettings.Headers.Warn,
 		DebugHeader:       c.AdvancedSettings.Headers.Debug,
 		ShowLogSystemName: *c.AdvancedSettings.ShowLogSystemName,
+		MaxLineBytes:      c.AdvancedSettings.MaxLineBytes,
 	}
 }
 
 func (l *Logger) newLogEvent(data, header, slName string, w io.Writer) error {
+	msg, ok := eventPool.Get().(*[]byte)
+	if !ok {
+		return errors.New("unable to type assert slice of bytes pointer")
+	}
+	*msg = append(*msg, data...)
+	err := l.writeLogEvent(*msg, header, slName, w)
+	*msg = (*msg)[:0]
+	eventPool.Put(msg)
+	return err
+}
+
+// newLogEventf formats the message into a pooled buffer rather than a string
+// before writing the log event, the output is identical to passing the
+// result of fmt.Sprintf to newLogEvent
+func (l *Logger) newLogEventf(format string, args []interface{}, header, slName string, w io.Writer) error {
+	msg, ok := eventPool.Get().(*[]byte)
+	if !ok {
+		return errors.New("unable to type assert slice of bytes pointer")
+	}
+	*msg = appendFormat(*msg, format, args)
+	err := l.writeLogEvent(*msg, header, slName, w)
+	*msg = (*msg)[:0]
+	eventPool.Put(msg)
+	return err
+}
+
+func (l *Logger) writeLogEvent(data []byte, header, slName string, w io.Writer) error {
 	if w == nil {
 		return errors.New("io.Writer not set")
 	}
+	if len(l.filter) > 0 && !matchesFilter(data, l.filter) {
+		return nil
+	}
 
 	pool, ok := eventPool.Get().(*[]byte)
 	if !ok {
@@ -42,11 +79,15 @@ func (l *Logger) newLogEvent(data, header, slName string, w io.Writer) error {
 	}
 	*pool = append(*pool, l.Spacer...)
 	if l.Timestamp != "" {
-		*pool = time.Now().AppendFormat(*pool, l.Timestamp)
+		*pool = timeNow().AppendFormat(*pool, l.Timestamp)
 	}
 	*pool = append(*pool, l.Spacer...)
-	*pool = append(*pool, data...)
-	if data == "" || data[len(data)-1] != '\n' {
+	if l.MaxLineBytes > 0 && len(data) > l.MaxLineBytes {
+		*pool = appendTruncated(*pool, data, l.MaxLineBytes)
+	} else {
+		*pool = append(*pool, data...)
+	}
+	if len(data) == 0 || (*pool)[len(*pool)-1] != '\n' {
 		*pool = append(*pool, '\n')
 	}
 	_, err := w.Write(*pool)
@@ -56,6 +97,142 @@ func (l *Logger) newLogEvent(data, header, slName string, w io.Writer) error {
 	return err
 }
 
+// matchesFilter returns whether data contains any of the filter substrings
+func matchesFilter(data []byte, filter [][]byte) bool {
+	for x := range filter {
+		if bytes.Contains(data, filter[x]) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendTruncated appends the first limit bytes of data to dst, backing off
+// so a multi-byte rune is not split, followed by a marker stating how many
+// bytes were dropped
+func appendTruncated(dst, data []byte, limit int) []byte {
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	dst = append(dst, data[:cut]...)
+	dst = append(dst, "...(truncated "...)
+	dst = strconv.AppendInt(dst, int64(len(data)-cut), 10)
+	return append(dst, " bytes)"...)
+}
+
+// appendFormat appends the equivalent of fmt.Sprintf(format, args...) to dst.
+// Formats made up of only %s, %d, %v and %% verbs, without flags or widths
+// and with an operand for every verb, are appended directly for string,
+// integer and bool operands. Other operands and formats go through fmt so
+// output always matches fmt.Sprintf
+func appendFormat(dst []byte, format string, args []interface{}) []byte {
+	if !isSimpleFormat(format, len(args)) {
+		return append(dst, fmt.Sprintf(format, args...)...)
+	}
+	for {
+		i := strings.IndexByte(format, '%')
+		if i < 0 {
+			return append(dst, format...)
+		}
+		dst = append(dst, format[:i]...)
+		verb := format[i+1]
+		format = format[i+2:]
+		if verb == '%' {
+			dst = append(dst, '%')
+			continue
+		}
+		dst = appendOperand(dst, verb, args[0])
+		args = args[1:]
+	}
+}
+
+// isSimpleFormat returns whether appendFormat can handle the format without
+// fmt, fmt reports missing, extra or unknown operands and verbs in the output
+// so those are left to it
+func isSimpleFormat(format string, operands int) bool {
+	verbs := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i == len(format) {
+			return false
+		}
+		switch format[i] {
+		case 's', 'd', 'v':
+			verbs++
+		case '%':
+		default:
+			return false
+		}
+	}
+	return verbs == operands
+}
+
+// appendOperand appends a single operand formatted with a %s, %d or %v verb
+func appendOperand(dst []byte, verb byte, arg interface{}) []byte {
+	switch v := arg.(type) {
+	case string:
+		if verb != 'd' {
+			return append(dst, v...)
+		}
+	case bool:
+		if verb == 'v' {
+			return strconv.AppendBool(dst, v)
+		}
+	case int:
+		if verb != 's' {
+			return strconv.AppendInt(dst, int64(v), 10)
+		}
+	case int8:
+		if verb != 's' {
+			return strconv.AppendInt(dst, int64(v), 10)
+		}
+	case int16:
+		if verb != 's' {
+			return strconv.AppendInt(dst, int64(v), 10)
+		}
+	case int32:
+		if verb != 's' {
+			return strconv.AppendInt(dst, int64(v), 10)
+		}
+	case int64:
+		if verb != 's' {
+			return strconv.AppendInt(dst, v, 10)
+		}
+	case uint:
+		if verb != 's' {
+			return strconv.AppendUint(dst, uint64(v), 10)
+		}
+	case uint8:
+		if verb != 's' {
+			return strconv.AppendUint(dst, uint64(v), 10)
+		}
+	case uint16:
+		if verb != 's' {
+			return strconv.AppendUint(dst, uint64(v), 10)
+		}
+	case uint32:
+		if verb != 's' {
+			return strconv.AppendUint(dst, uint64(v), 10)
+		}
+	case uint64:
+		if verb != 's' {
+			return strconv.AppendUint(dst, v, 10)
+		}
+	}
+	switch verb {
+	case 's':
+		return append(dst, fmt.Sprintf("%s", arg)...)
+	case 'd':
+		return append(dst, fmt.Sprintf("%d", arg)...)
+	default:
+		return append(dst, fmt.Sprint(arg)...)
+	}
+}
+
 // CloseLogger is called on shutdown of application
 func CloseLogger() error {
 	return GlobalLogFile.Close()
@@ -65,29 +242,161 @@ func CloseLogger() error {
 func Level(name string) (Levels, error) {
 	RWM.RLock()
 	defer RWM.RUnlock()
-	subLogger, found := SubLoggers[name]
+	subLogger, found := subLoggers[name]
 	if !found {
 		return Levels{}, fmt.Errorf("logger %s not found", name)
 	}
 	return subLogger.levels, nil
 }
 
-// SetLevel sets sublogger levels
+// SetLevel sets sublogger levels, cancelling any pending timed revert
 func SetLevel(s, level string) (Levels, error) {
 	RWM.Lock()
 	defer RWM.Unlock()
-	subLogger, found := SubLoggers[s]
+	subLogger, found := subLoggers[s]
 	if !found {
 		return Levels{}, fmt.Errorf("sub logger %v not found", s)
 	}
 	subLogger.SetLevels(splitLevel(level))
-	return subLogger.levels, nil
+	levelReverts.schedule(subLogger, 0)
+	return subLogger.GetLevels(), nil
+}
+
+// SubLoggerLevels returns the current levels of every registered sub logger
+// keyed by sub logger name
+func SubLoggerLevels() map[string]Levels {
+	RWM.RLock()
+	defer RWM.RUnlock()
+	levels := make(map[string]Levels, len(subLoggers))
+	for name, subLogger := range subLoggers {
+		levels[name] = subLogger.GetLevels()
+	}
+	return levels
+}
+
+// ExportLevels returns the levels of every registered sub logger keyed by sub
+// logger name for persisting across restarts. Sub loggers with a pending
+// timed revert report the levels they will revert to, so temporary changes
+// are not persisted
+func ExportLevels() map[string]Levels {
+	RWM.RLock()
+	defer RWM.RUnlock()
+	levelReverts.mu.Lock()
+	defer levelReverts.mu.Unlock()
+	levels := make(map[string]Levels, len(subLoggers))
+	for name, subLogger := range subLoggers {
+		if pending, ok := levelReverts.pending[subLogger]; ok {
+			levels[name] = pending.levels
+			continue
+		}
+		levels[name] = subLogger.GetLevels()
+	}
+	return levels
+}
+
+// ImportLevels sets the levels of each named sub logger, cancelling any
+// pending timed revert, as exported by ExportLevels. Names which are not
+// registered are skipped and returned
+func ImportLevels(levels map[string]Levels) (unknown []string) {
+	RWM.Lock()
+	defer RWM.Unlock()
+	for name, l := range levels {
+		subLogger, found := subLoggers[strings.ToUpper(name)]
+		if !found {
+			unknown = append(unknown, name)
+			continue
+		}
+		subLogger.SetLevels(l)
+		levelReverts.schedule(subLogger, 0)
+	}
+	return unknown
+}
+
+// SetLevelPattern sets the levels of every sub logger whose name matches the
+// glob pattern, e.g. "SYNC*", and returns the affected sub loggers. When
+// revertAfter is above zero the levels held before the first pending change
+// are restored once it elapses, unless superseded by another change.
+func SetLevelPattern(pattern, level string, revertAfter time.Duration) (map[string]Levels, error) {
+	pattern = strings.ToUpper(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid sub logger pattern %s: %w", pattern, err)
+	}
+	if err := validateLevel(level); err != nil {
+		return nil, err
+	}
+	newLevels := splitLevel(level)
+
+	RWM.Lock()
+	defer RWM.Unlock()
+	changed := make(map[string]Levels)
+	for name, subLogger := range subLoggers {
+		if match, _ := path.Match(pattern, name); !match {
+			continue
+		}
+		levelReverts.schedule(subLogger, revertAfter)
+		subLogger.SetLevels(newLevels)
+		changed[name] = newLevels
+	}
+	if len(changed) == 0 {
+		return nil, fmt.Errorf("%w: %s", errNoSubLoggersMatched, pattern)
+	}
+	return changed, nil
+}
+
+// schedule restores the current levels of the sub logger after the supplied
+// duration, a duration of zero or less cancels any pending revert. If a
+// revert is already pending its original levels are kept so chained changes
+// still revert to the levels held before the first one.
+func (r *revertScheduler) schedule(sl *SubLogger, after time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := sl.GetLevels()
+	if pending, ok := r.pending[sl]; ok {
+		pending.timer.Stop()
+		previous = pending.levels
+		delete(r.pending, sl)
+	}
+	if after <= 0 {
+		return
+	}
+	p := &pendingRevert{levels: previous}
+	p.timer = time.AfterFunc(after, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.pending[sl] != p {
+			return
+		}
+		delete(r.pending, sl)
+		sl.SetLevels(p.levels)
+	})
+	if r.pending == nil {
+		r.pending = make(map[*SubLogger]*pendingRevert)
+	}
+	r.pending[sl] = p
+}
+
+// String returns the enabled levels in the pipe separated config format
+func (l Levels) String() string {
+	var enabled []string
+	if l.Info {
+		enabled = append(enabled, "INFO")
+	}
+	if l.Debug {
+		enabled = append(enabled, "DEBUG")
+	}
+	if l.Warn {
+		enabled = append(enabled, "WARN")
+	}
+	if l.Error {
+		enabled = append(enabled, "ERROR")
+	}
+	return strings.Join(enabled, "|")
 }
 
 // Info takes a pointer subLogger struct and string sends to newLogEvent
 func Info(sl *SubLogger, data string) {
-	fields := sl.getFields()
-	if fields == nil || !fields.info {
+	fields, ok := sl.getFields()
+	if !ok || !fields.info {
 		return
 	}
 
@@ -99,8 +408,8 @@ func Info(sl *SubLogger, data string) {
 
 // Infoln takes a pointer subLogger struct and interface sends to newLogEvent
 func Infoln(sl *SubLogger, v ...interface{}) {
-	fields := sl.getFields()
-	if fields == nil || !fields.info {
+	fields, ok := sl.getFields()
+	if !ok || !fields.info {
 		return
 	}
 	displayError(fields.logger.newLogEvent(fmt.Sprintln(v...),
@@ -109,15 +418,23 @@ func Infoln(sl *SubLogger, v ...interface{}) {
 		fields.output))
 }
 
-// Infof takes a pointer subLogger struct, string & interface formats and sends to Info()
+// Infof takes a pointer subLogger struct, string & interface formats and sends
+// to newLogEventf, formatting is skipped when the level is disabled
 func Infof(sl *SubLogger, data string, v ...interface{}) {
-	Info(sl, fmt.Sprintf(data, v...))
+	fields, ok := sl.getFields()
+	if !ok || !fields.info {
+		return
+	}
+	displayError(fields.logger.newLogEventf(data, v,
+		fields.logger.InfoHeader,
+		fields.name,
+		fields.output))
 }
 
 // Debug takes a pointer subLogger struct and string sends to multiwriter
 func Debug(sl *SubLogger, data string) {
-	fields := sl.getFields()
-	if fields == nil || !fields.debug {
+	fields, ok := sl.getFields()
+	if !ok || !fields.debug {
 		return
 	}
 	displayError(fields.logger.newLogEvent(data,
@@ -128,8 +445,8 @@ func Debug(sl *SubLogger, data string) {
 
 // Debugln  takes a pointer subLogger struct, string and interface sends to newLogEvent
 func Debugln(sl *SubLogger, v ...interface{}) {
-	fields := sl.getFields()
-	if fields == nil || !fields.debug {
+	fields, ok := sl.getFields()
+	if !ok || !fields.debug {
 		return
 	}
 
@@ -139,15 +456,23 @@ func Debugln(sl *SubLogger, v ...interface{}) {
 		fields.output))
 }
 
-// Debugf takes a pointer subLogger struct, string & interface formats and sends to Info()
+// Debugf takes a pointer subLogger struct, string & interface formats and sends
+// to newLogEventf, formatting is skipped when the level is disabled
 func Debugf(sl *SubLogger, data string, v ...interface{}) {
-	Debug(sl, fmt.Sprintf(data, v...))
+	fields, ok := sl.getFields()
+	if !ok || !fields.debug {
+		return
+	}
+	displayError(fields.logger.newLogEventf(data, v,
+		fields.logger.DebugHeader,
+		fields.name,
+		fields.output))
 }
 
 // Warn takes a pointer subLogger struct & string  and sends to newLogEvent()
 func Warn(sl *SubLogger, data string) {
-	fields := sl.getFields()
-	if fields == nil || !fields.warn {
+	fields, ok := sl.getFields()
+	if !ok || !fields.warn {
 		return
 	}
 	displayError(fields.logger.newLogEvent(data,
@@ -158,8 +483,8 @@ func Warn(sl *SubLogger, data string) {
 
 // Warnln takes a pointer subLogger struct & interface formats and sends to newLogEvent()
 func Warnln(sl *SubLogger, v ...interface{}) {
-	fields := sl.getFields()
-	if fields == nil || !fields.warn {
+	fields, ok := sl.getFields()
+	if !ok || !fields.warn {
 		return
 	}
 	displayError(fields.logger.newLogEvent(fmt.Sprintln(v...),
@@ -168,15 +493,23 @@ func Warnln(sl *SubLogger, v ...interface{}) {
 		fields.output))
 }
 
-// Warnf takes a pointer subLogger struct, string & interface formats and sends to Warn()
+// Warnf takes a pointer subLogger struct, string & interface formats and sends
+// to newLogEventf, formatting is skipped when the level is disabled
 func Warnf(sl *SubLogger, data string, v ...interface{}) {
-	Warn(sl, fmt.Sprintf(data, v...))
+	fields, ok := sl.getFields()
+	if !ok || !fields.warn {
+		return
+	}
+	displayError(fields.logger.newLogEventf(data, v,
+		fields.logger.WarnHeader,
+		fields.name,
+		fields.output))
 }
 
 // Error takes a pointer subLogger struct & interface formats and sends to newLogEvent()
 func Error(sl *SubLogger, data ...interface{}) {
-	fields := sl.getFields()
-	if fields == nil || !fields.error {
+	fields, ok := sl.getFields()
+	if !ok || !fields.error {
 		return
 	}
 	displayError(fields.logger.newLogEvent(fmt.Sprint(data...),
@@ -187,8 +520,8 @@ func Error(sl *SubLogger, data ...interface{}) {
 
 // Errorln takes a pointer subLogger struct, string & interface formats and sends to newLogEvent()
 func Errorln(sl *SubLogger, v ...interface{}) {
-	fields := sl.getFields()
-	if fields == nil || !fields.error {
+	fields, ok := sl.getFields()
+	if !ok || !fields.error {
 		return
 	}
 	displayError(fields.logger.newLogEvent(fmt.Sprintln(v...),
@@ -197,13 +530,21 @@ func Errorln(sl *SubLogger, v ...interface{}) {
 		fields.output))
 }
 
-// Errorf takes a pointer subLogger struct, string & interface formats and sends to Debug()
+// Errorf takes a pointer subLogger struct, string & interface formats and sends
+// to newLogEventf, formatting is skipped when the level is disabled
 func Errorf(sl *SubLogger, data string, v ...interface{}) {
-	Error(sl, fmt.Sprintf(data, v...))
+	fields, ok := sl.getFields()
+	if !ok || !fields.error {
+		return
+	}
+	displayError(fields.logger.newLogEventf(data, v,
+		fields.logger.ErrorHeader,
+		fields.name,
+		fields.output))
 }
 
 func displayError(err error) {
 	if err != nil {
 		log.Printf("Logger write error: %v\n", err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,439 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// getTestSubLogger registers a sub logger for testing or returns the one
+// registered by a previous run of the test
+func getTestSubLogger(t *testing.T, name string) *SubLogger {
+	t.Helper()
+	sl, err := NewSubLogger(name)
+	if errors.Is(err, errSubLoggerAlreadyregistered) {
+		sl, _ = GetSubLogger(name)
+		return sl
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sl
+}
+
+func TestSetLevelPattern(t *testing.T) {
+	t.Parallel()
+	a := getTestSubLogger(t, "patterna")
+	b := getTestSubLogger(t, "patternb")
+	other := getTestSubLogger(t, "otherpattern")
+	for _, sl := range []*SubLogger{a, b, other} {
+		sl.SetLevels(splitLevel("ERROR"))
+	}
+
+	if _, err := SetLevelPattern("[", "DEBUG", 0); err == nil {
+		t.Fatal("expected error for malformed pattern")
+	}
+	if _, err := SetLevelPattern("PATTERN*", "VERBOSE", 0); !errors.Is(err, errInvalidLogLevel) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidLogLevel)
+	}
+	if _, err := SetLevelPattern("NOPE*", "DEBUG", 0); !errors.Is(err, errNoSubLoggersMatched) {
+		t.Fatalf("received: %v but expected: %v", err, errNoSubLoggersMatched)
+	}
+
+	changed, err := SetLevelPattern("pattern*", "DEBUG|ERROR", time.Millisecond*50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 {
+		t.Fatalf("expected 2 sub loggers changed, received %v", changed)
+	}
+	if !a.GetLevels().Debug || !b.GetLevels().Debug {
+		t.Fatal("expected matching sub loggers to have debug enabled")
+	}
+	if other.GetLevels().Debug {
+		t.Fatal("expected non matching sub logger to be untouched")
+	}
+
+	// A chained change keeps the original levels to revert to
+	if _, err = SetLevelPattern("PATTERNA", "INFO", time.Millisecond*50); err != nil {
+		t.Fatal(err)
+	}
+	// An explicit change cancels the pending revert
+	if _, err = SetLevel("PATTERNB", "WARN"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for a.GetLevels() != splitLevel("ERROR") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected levels to revert, received %v", a.GetLevels())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if levels := b.GetLevels(); levels != splitLevel("WARN") {
+		t.Fatalf("expected explicit level to remain, received %v", levels)
+	}
+}
+
+func TestSetLevelPatternConcurrentLogging(t *testing.T) {
+	t.Parallel()
+	sl := getTestSubLogger(t, "concurrentlevels")
+	sl.SetOutput(io.Discard)
+	sl.SetLevels(splitLevel("ERROR"))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					Debugf(sl, "price %v", 1337.0)
+					Errorln(sl, "error")
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := SetLevelPattern("CONCURRENT*", "DEBUG|ERROR", time.Millisecond); err != nil {
+			t.Error(err)
+		}
+		_ = SubLoggerLevels()
+	}
+	close(stop)
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for sl.GetLevels() != splitLevel("ERROR") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected levels to revert, received %v", sl.GetLevels())
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestExportImportLevels(t *testing.T) {
+	t.Parallel()
+	a := getTestSubLogger(t, "exporta")
+	b := getTestSubLogger(t, "exportb")
+	a.SetLevels(splitLevel("WARN"))
+	b.SetLevels(splitLevel("INFO"))
+	// A temporary change is exported as the levels it reverts to
+	if _, err := SetLevelPattern("EXPORTB", "DEBUG|ERROR", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := ExportLevels()
+	if exported["EXPORTA"] != splitLevel("WARN") {
+		t.Errorf("received: %v but expected: %v", exported["EXPORTA"], splitLevel("WARN"))
+	}
+	if exported["EXPORTB"] != splitLevel("INFO") {
+		t.Errorf("received: %v but expected: %v", exported["EXPORTB"], splitLevel("INFO"))
+	}
+
+	a.SetLevels(splitLevel("ERROR"))
+	// Only import this test's sub loggers as others are changed in parallel
+	unknown := ImportLevels(map[string]Levels{
+		"exporta":     exported["EXPORTA"],
+		"EXPORTB":     exported["EXPORTB"],
+		"EXPORTNOTES": splitLevel("DEBUG"),
+	})
+	if len(unknown) != 1 || unknown[0] != "EXPORTNOTES" {
+		t.Errorf("received: %v but expected: %v", unknown, []string{"EXPORTNOTES"})
+	}
+	if levels := a.GetLevels(); levels != splitLevel("WARN") {
+		t.Errorf("received: %v but expected: %v", levels, splitLevel("WARN"))
+	}
+	if levels := b.GetLevels(); levels != splitLevel("INFO") {
+		t.Errorf("received: %v but expected: %v", levels, splitLevel("INFO"))
+	}
+	levelReverts.mu.Lock()
+	_, pending := levelReverts.pending[b]
+	levelReverts.mu.Unlock()
+	if pending {
+		t.Error("expected import to cancel the pending revert")
+	}
+}
+
+func TestLevelsString(t *testing.T) {
+	t.Parallel()
+	if s := splitLevel("ERROR|DEBUG").String(); s != "DEBUG|ERROR" {
+		t.Fatalf("received: %s but expected: DEBUG|ERROR", s)
+	}
+	if s := (Levels{}).String(); s != "" {
+		t.Fatalf("received: %s but expected empty string", s)
+	}
+}
+
+func TestNewLogEventMaxLineBytes(t *testing.T) {
+	t.Parallel()
+	l := Logger{
+		InfoHeader:   "[INFO]",
+		Spacer:       " | ",
+		MaxLineBytes: 10,
+	}
+	var buf strings.Builder
+	err := l.newLogEvent(strings.Repeat("a", 25), l.InfoHeader, "TEST", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[INFO] |  | aaaaaaaaaa...(truncated 15 bytes)\n"
+	if buf.String() != expected {
+		t.Errorf("received: %q but expected: %q", buf.String(), expected)
+	}
+
+	// A multi-byte rune straddling the limit is dropped whole
+	buf.Reset()
+	err = l.newLogEvent("aaaaaaaaa€bc\n", l.InfoHeader, "TEST", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "[INFO] |  | aaaaaaaaa...(truncated 6 bytes)\n"
+	if buf.String() != expected {
+		t.Errorf("received: %q but expected: %q", buf.String(), expected)
+	}
+
+	// Lines within the limit and an unlimited logger are untouched
+	buf.Reset()
+	l.MaxLineBytes = 0
+	err = l.newLogEvent(strings.Repeat("a", 25), l.InfoHeader, "TEST", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "truncated") {
+		t.Errorf("received: %q but expected no truncation", buf.String())
+	}
+}
+
+type testStringer struct{}
+
+func (testStringer) String() string { return "stringer" }
+
+var formatGoldens = []struct {
+	format string
+	args   []interface{}
+}{
+	{"no verbs", nil},
+	{"pair %s count %d\n", []interface{}{"BTC-USD", 1234}},
+	{"%v %v %v %v", []interface{}{"a", -5, true, uint64(18446744073709551615)}},
+	{"%d%%", []interface{}{int8(-100)}},
+	{"%d %d %d %d %d", []interface{}{int16(1), int32(2), int64(3), uint8(4), uint16(5)}},
+	{"%v %s", []interface{}{uint(6), "x"}},
+	{"%s", []interface{}{uint32(7)}},
+	{"%d", []interface{}{"str"}},
+	{"%s", []interface{}{true}},
+	{"%v %s", []interface{}{nil, nil}},
+	{"%v %s", []interface{}{errors.New("err"), errors.New("err")}},
+	{"%v %s %d", []interface{}{testStringer{}, testStringer{}, testStringer{}}},
+	{"%v", []interface{}{1.5}},
+	{"%0.2f %x", []interface{}{1.234, 255}},
+	{"%5s|%-5d", []interface{}{"a", 1}},
+	{"%[2]s %[1]s", []interface{}{"a", "b"}},
+	{"missing %s %d", []interface{}{"a"}},
+	{"extra %s", []interface{}{"a", "b"}},
+	{"trailing %", nil},
+	{"%q", []interface{}{"quoted"}},
+	{"%s", []interface{}{[]byte("bytes")}},
+}
+
+func TestAppendFormat(t *testing.T) {
+	t.Parallel()
+	for x := range formatGoldens {
+		expected := fmt.Sprintf(formatGoldens[x].format, formatGoldens[x].args...)
+		received := string(appendFormat([]byte("prefix"), formatGoldens[x].format, formatGoldens[x].args))
+		if received != "prefix"+expected {
+			t.Errorf("%q received: %q but expected: %q", formatGoldens[x].format, received, "prefix"+expected)
+		}
+	}
+}
+
+func TestNewLogEventf(t *testing.T) {
+	t.Parallel()
+	l := Logger{
+		InfoHeader:        "[INFO]",
+		Spacer:            " | ",
+		ShowLogSystemName: true,
+		MaxLineBytes:      12,
+	}
+	for x := range formatGoldens {
+		var expected, received strings.Builder
+		err := l.newLogEvent(fmt.Sprintf(formatGoldens[x].format, formatGoldens[x].args...), l.InfoHeader, "TEST", &expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = l.newLogEventf(formatGoldens[x].format, formatGoldens[x].args, l.InfoHeader, "TEST", &received)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received.String() != expected.String() {
+			t.Errorf("%q received: %q but expected: %q", formatGoldens[x].format, received.String(), expected.String())
+		}
+	}
+}
+
+func benchmarkSubLogger(b *testing.B, name, level string) *SubLogger {
+	b.Helper()
+	sl, err := NewSubLogger(name)
+	if errors.Is(err, errSubLoggerAlreadyregistered) {
+		sl, _ = GetSubLogger(name)
+	} else if err != nil {
+		b.Fatal(err)
+	}
+	sl.SetOutput(io.Discard)
+	sl.SetLevels(splitLevel(level))
+	return sl
+}
+
+// 0 allocs/op
+func BenchmarkInfofDisabled(b *testing.B) {
+	sl := benchmarkSubLogger(b, "benchdisabled", "ERROR")
+	pair, count := "BTC-USD", 1234
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Infof(sl, "pair %s count %d\n", pair, count)
+	}
+}
+
+// 0 allocs/op
+func BenchmarkInfofEnabled(b *testing.B) {
+	sl := benchmarkSubLogger(b, "benchenabled", "INFO")
+	pair, count := "BTC-USD", 1234
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Infof(sl, "pair %s count %d\n", pair, count)
+	}
+}
+
+// 1 allocs/op, formatting through fmt.Sprintf as Infof did previously
+func BenchmarkInfoSprintf(b *testing.B) {
+	sl := benchmarkSubLogger(b, "benchsprintf", "INFO")
+	pair, count := "BTC-USD", 1234
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Info(sl, fmt.Sprintf("pair %s count %d\n", pair, count))
+	}
+}
+
+// Not parallel as it replaces the package time source
+func TestNewLogEventTimestamp(t *testing.T) {
+	frozen := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	timeNow = func() time.Time { return frozen }
+	defer func() { timeNow = time.Now }()
+
+	l := Logger{
+		InfoHeader: "[INFO]",
+		Spacer:     " | ",
+		Timestamp:  timestampFormat,
+	}
+	var buf strings.Builder
+	err := l.newLogEvent("frozen", l.InfoHeader, "TEST", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[INFO] |  04/03/2021 05:06:07  | frozen\n"
+	if buf.String() != expected {
+		t.Errorf("received: %q but expected: %q", buf.String(), expected)
+	}
+}
+
+func TestDisabledSubLogger(t *testing.T) {
+	t.Parallel()
+	sl := getTestSubLogger(t, "DISABLEDTEST")
+	cfg := GenDefaultSettings()
+	cfg.SubLoggers = []SubLoggerConfig{{Name: "disabledtest", Level: LevelNone, Output: "stdout"}}
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.SubLoggers[0].Level = LevelNone + "|INFO"
+	if err := ValidateConfig(cfg); !errors.Is(err, errInvalidLogLevel) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidLogLevel)
+	}
+
+	if _, err := SetLevel("DISABLEDTEST", LevelNone); err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	sl.SetOutput(&buf)
+	if _, ok := sl.getFields(); ok {
+		t.Fatal("expected disabled sub logger to return no fields")
+	}
+	Info(sl, "info")
+	Infof(sl, "info %d", 1)
+	Infoln(sl, "info")
+	Debug(sl, "debug")
+	Debugf(sl, "debug %d", 1)
+	Debugln(sl, "debug")
+	Warn(sl, "warn")
+	Warnf(sl, "warn %d", 1)
+	Warnln(sl, "warn")
+	Error(sl, "error")
+	Errorf(sl, "error %d", 1)
+	Errorln(sl, "error")
+	if buf.Len() != 0 {
+		t.Fatalf("received: %q but expected no output", buf.String())
+	}
+
+	if _, err := SetLevel("DISABLEDTEST", "ERROR"); err != nil {
+		t.Fatal(err)
+	}
+	Error(sl, "error")
+	if !strings.Contains(buf.String(), "error") {
+		t.Fatalf("received: %q but expected output once re-enabled", buf.String())
+	}
+}
+
+func TestSubLoggerAccessConcurrent(t *testing.T) {
+	t.Parallel()
+	if _, ok := GetSubLogger("log"); !ok {
+		t.Fatal("expected global sub logger to be registered")
+	}
+	if _, ok := GetSubLogger("doesnotexist"); ok {
+		t.Fatal("expected unknown sub logger not to be found")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for x := 0; x < 50; x++ {
+			_, err := NewSubLogger(fmt.Sprintf("foreachreg%d", x))
+			if err != nil && !errors.Is(err, errSubLoggerAlreadyregistered) {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for x := 0; x < 50; x++ {
+			ForEachSubLogger(func(name string, sl *SubLogger) {
+				if sl == nil || sl.name != name {
+					t.Errorf("sub logger %s has mismatched name", name)
+				}
+				sl.GetLevels()
+			})
+		}
+	}()
+	wg.Wait()
+
+	var found int
+	ForEachSubLogger(func(name string, _ *SubLogger) {
+		if strings.HasPrefix(name, "FOREACHREG") {
+			found++
+		}
+	})
+	if found != 50 {
+		t.Fatalf("received: %d but expected: %d", found, 50)
+	}
+	if _, err := NewSubLogger("foreachreg0"); !errors.Is(err, errSubLoggerAlreadyregistered) {
+		t.Fatalf("received: %v but expected: %v", err, errSubLoggerAlreadyregistered)
+	}
+}
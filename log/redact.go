@@ -0,0 +1,77 @@
+package log
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// RedactedValue replaces sensitive values removed by Redact
+	RedactedValue = "[REDACTED]"
+	// minSensitiveValueLen stops short values, which would redact common
+	// words or numbers, from being registered
+	minSensitiveValueLen = 4
+)
+
+var (
+	sensitive = struct {
+		mtx      sync.RWMutex
+		values   map[string]struct{}
+		replacer *strings.Replacer
+	}{values: make(map[string]struct{})}
+
+	// sensitivePatterns match credentials which are not registered, such as
+	// signatures computed per request. The first two groups are kept
+	sensitivePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(authorization)(["']?\s*[:=]\s*\[?["']?)(?:(?:bearer|basic)\s+)?[^\s"',\]]+`),
+		regexp.MustCompile(`(?i)([a-z_-]*(?:api[_-]?key|secret|signature|sign|token|passphrase|password)[a-z_-]*)(["']?\s*[:=]\s*\[?["']?)[^\s&"',\]]+`),
+		regexp.MustCompile(`()()\b[0-9a-fA-F]{64}\b`),
+	}
+)
+
+// RegisterSensitiveValue adds a credential, such as an API secret loaded at
+// engine start, which Redact removes wherever it appears. Values shorter than
+// four characters are ignored
+func RegisterSensitiveValue(v string) {
+	if len(v) < minSensitiveValueLen {
+		return
+	}
+	sensitive.mtx.Lock()
+	defer sensitive.mtx.Unlock()
+	if _, ok := sensitive.values[v]; ok {
+		return
+	}
+	sensitive.values[v] = struct{}{}
+	values := make([]string, 0, len(sensitive.values))
+	for value := range sensitive.values {
+		values = append(values, value)
+	}
+	// Longest first so a value containing another is replaced whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	pairs := make([]string, 0, len(values)*2)
+	for x := range values {
+		pairs = append(pairs, values[x], RedactedValue)
+	}
+	sensitive.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with registered sensitive values, authorization headers,
+// key, secret, signature and token parameters and 64 character hex
+// signatures replaced by RedactedValue. It is used for verbose HTTP logging
+// and is available to anything logging request or response data
+func Redact(s string) string {
+	for x := range sensitivePatterns {
+		s = sensitivePatterns[x].ReplaceAllString(s, "${1}${2}"+RedactedValue)
+	}
+	// Registered values are replaced last so a value already removed by a
+	// pattern is not wrapped a second time
+	sensitive.mtx.RLock()
+	replacer := sensitive.replacer
+	sensitive.mtx.RUnlock()
+	if replacer != nil {
+		s = replacer.Replace(s)
+	}
+	return s
+}
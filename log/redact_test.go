@@ -0,0 +1,34 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+	RegisterSensitiveValue("redact-test-secret")
+	RegisterSensitiveValue("redact-test-secret-longer")
+	RegisterSensitiveValue("abc")
+
+	sig := strings.Repeat("a1", 32)
+	for _, tc := range []struct {
+		in, expected string
+	}{
+		{"nothing to see here", "nothing to see here"},
+		{"key redact-test-secret-longer used", "key [REDACTED] used"},
+		{"secret redact-test-secret used", "secret [REDACTED] used"},
+		{"abc is too short to register", "abc is too short to register"},
+		{"Authorization: Bearer eyJhbGciOi", "Authorization: [REDACTED]"},
+		{`{"apiKey":"k123","symbol":"BTC"}`, `{"apiKey":"[REDACTED]","symbol":"BTC"}`},
+		{"/order?symbol=BTC&signature=s1&api_key=k2", "/order?symbol=BTC&signature=[REDACTED]&api_key=[REDACTED]"},
+		{"X-MBX-APIKEY: [k3]", "X-MBX-APIKEY: [[REDACTED]]"},
+		{"signed " + sig + " payload", "signed [REDACTED] payload"},
+		{"order 12345 filled", "order 12345 filled"},
+		{"secret=redact-test-secret&side=BUY", "secret=[REDACTED]&side=BUY"},
+	} {
+		if got := Redact(tc.in); got != tc.expected {
+			t.Errorf("%q received: %q but expected: %q", tc.in, got, tc.expected)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"os"
@@ -12,27 +13,36 @@ import (
 )
 
 const (
-	defaultMaxSize int64 = 250
-	megabyte       int64 = 1024 * 1024
+	defaultMaxSize       int64 = 250
+	megabyte             int64 = 1024 * 1024
+	defaultFlushInterval       = time.Second
 )
+
 var (
 	errExceedsMaxFileSize = errors.New("exceeds max file size")
 	errFileNameIsEmpty    = errors.New("filename is empty")
 )
 
-
 // Rotate struct for each instance of Rotate
 type Rotate struct {
 	FileName string
 	Rotate   *bool
 	MaxSize  int64
-
-	size   int64
-	output *os.File
-	mu     sync.Mutex
+	// BufferSize is the amount of bytes held in memory before being written
+	// to the file, zero writes straight through. Buffered data is written at
+	// least every FlushInterval and on Close, so a crash loses at most one
+	// flush interval (or one buffer) of log lines.
+	BufferSize    int
+	FlushInterval time.Duration
+
+	size      int64
+	output    *os.File
+	buffer    *bufio.Writer
+	flushStop chan struct{}
+	flushWG   sync.WaitGroup
+	mu        sync.Mutex
 }
 
-
 // Write implementation to satisfy io.Writer handles length check and rotation
 func (r *Rotate) Write(output []byte) (n int, err error) {
 	r.mu.Lock()
@@ -61,11 +71,60 @@ func (r *Rotate) Write(output []byte) (n int, err error) {
 		}
 	}
 
-	n, err = r.output.Write(output)
+	if r.buffer != nil {
+		n, err = r.buffer.Write(output)
+	} else {
+		n, err = r.output.Write(output)
+	}
 	r.size += int64(n)
 	return n, err
 }
 
+// setOutput sets the file written to and, if buffering is enabled, points the
+// buffer at it and starts the periodic flush routine
+func (r *Rotate) setOutput(f *os.File) {
+	r.output = f
+	if r.BufferSize <= 0 {
+		return
+	}
+	if r.buffer == nil {
+		r.buffer = bufio.NewWriterSize(f, r.BufferSize)
+	} else {
+		r.buffer.Reset(f)
+	}
+	if r.flushStop != nil {
+		return
+	}
+	interval := r.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	r.flushStop = make(chan struct{})
+	r.flushWG.Add(1)
+	go r.flushRoutine(interval, r.flushStop)
+}
+
+// flushRoutine periodically writes buffered data to the file until stopped
+func (r *Rotate) flushRoutine(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer func() {
+		t.Stop()
+		r.flushWG.Done()
+	}()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			r.mu.Lock()
+			if r.output != nil && r.buffer != nil {
+				displayError(r.buffer.Flush())
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
 func (r *Rotate) openOrCreateFile(n int64) error {
 	logFile := filepath.Join(LogPath, r.FileName)
 	info, err := os.Stat(logFile)
@@ -87,7 +146,7 @@ func (r *Rotate) openOrCreateFile(n int64) error {
 		return r.openNew()
 	}
 
-	r.output = file
+	r.setOutput(file)
 	r.size = info.Size()
 
 	return nil
@@ -101,7 +160,7 @@ func (r *Rotate) openNew() error {
 	_, err := os.Stat(name)
 
 	if err == nil {
-		timestamp := time.Now().Format("2006-01-02T15-04-05")
+		timestamp := timeNow().Format("2006-01-02T15-04-05")
 		newName := filepath.Join(LogPath, timestamp+"-"+r.FileName)
 
 		err = file.Move(name, newName)
@@ -115,7 +174,7 @@ func (r *Rotate) openNew() error {
 		return fmt.Errorf("can't open new logfile: %s", err)
 	}
 
-	r.output = file
+	r.setOutput(file)
 	r.size = 0
 	return nil
 }
@@ -124,16 +183,28 @@ func (r *Rotate) close() (err error) {
 	if r.output == nil {
 		return nil
 	}
-	err = r.output.Close()
+	if r.buffer != nil {
+		err = r.buffer.Flush()
+	}
+	if closeErr := r.output.Close(); err == nil {
+		err = closeErr
+	}
 	r.output = nil
 	return err
 }
 
-// Close handler for open file
+// Close flushes any buffered data and closes the open file
 func (r *Rotate) Close() error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-	return r.close()
+	err := r.close()
+	stop := r.flushStop
+	r.flushStop = nil
+	r.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		r.flushWG.Wait()
+	}
+	return err
 }
 
 func (r *Rotate) rotateFile() (err error) {
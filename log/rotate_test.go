@@ -0,0 +1,94 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhiwei-w-luo/gotradebot/common/convert"
+)
+
+var testLogLine = []byte("[INFO] | SYNC | 02/01/2006 15:04:05 | Ticker updated for BTC-USDT on some exchange\n")
+
+func TestRotateBufferedWrite(t *testing.T) {
+	LogPath = t.TempDir()
+	r := &Rotate{
+		FileName:      "buffered.log",
+		Rotate:        convert.BoolPtr(false),
+		BufferSize:    4096,
+		FlushInterval: time.Millisecond * 10,
+	}
+	if _, err := r.Write(testLogLine); err != nil {
+		t.Fatal(err)
+	}
+
+	logFile := filepath.Join(LogPath, r.FileName)
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Fatal("expected write to be buffered")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !bytes.Equal(data, testLogLine) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected buffered data to be flushed on interval")
+		}
+		time.Sleep(time.Millisecond * 5)
+		data, err = os.ReadFile(logFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.FlushInterval = time.Hour
+	if _, err = r.Write(testLogLine); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, append(append([]byte{}, testLogLine...), testLogLine...)) {
+		t.Fatalf("expected buffered data to be flushed on close, received %q", data)
+	}
+}
+
+func benchmarkRotateWrite(b *testing.B, bufferSize int) {
+	LogPath = b.TempDir()
+	r := &Rotate{
+		FileName:   "bench.log",
+		Rotate:     convert.BoolPtr(false),
+		MaxSize:    1024,
+		BufferSize: bufferSize,
+	}
+	b.SetBytes(int64(len(testLogLine)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Write(testLogLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if err := r.Close(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkRotateWriteUnbuffered 	  200000	       753.8 ns/op	 110.10 MB/s	       0 B/op	       0 allocs/op
+func BenchmarkRotateWriteUnbuffered(b *testing.B) {
+	benchmarkRotateWrite(b, 0)
+}
+
+// BenchmarkRotateWriteBuffered   	  200000	        53.09 ns/op	1563.32 MB/s	       0 B/op	       0 allocs/op
+func BenchmarkRotateWriteBuffered(b *testing.B) {
+	benchmarkRotateWrite(b, 64*1024)
+}
@@ -13,6 +13,11 @@ import (
 var (
 	errSubloggerConfigIsNil  = errors.New("sublogger config is nil")
 	errUnhandledOutputWriter = errors.New("unhandled output writer")
+	errLoggingConfigIsNil    = errors.New("logging config is nil")
+	errRequiredFieldUnset    = errors.New("required field is unset")
+	errInvalidLogLevel       = errors.New("invalid log level")
+	errInvalidMaxLineBytes   = errors.New("max line bytes cannot be negative")
+	errGlobalMaxLineBytes    = errors.New("maxLineBytes is only valid for sub logger entries, use advancedSettings.maxLineBytes")
 )
 
 func getWriters(s *SubLoggerConfig) (io.Writer, error) {
@@ -69,33 +74,51 @@ func GenDefaultSettings() *Config {
 	}
 }
 
-func configureSubLogger(subLogger, levels string, output io.Writer) error {
+func configureSubLogger(subLogger string, s *SubLoggerConfig, output io.Writer) error {
 	RWM.Lock()
 	defer RWM.Unlock()
-	logPtr, found := SubLoggers[subLogger]
+	logPtr, found := subLoggers[subLogger]
 	if !found {
 		return fmt.Errorf("sub logger %v not found", subLogger)
 	}
 
 	logPtr.SetOutput(output)
-	logPtr.SetLevels(splitLevel(levels))
-	SubLoggers[subLogger] = logPtr
+	logPtr.SetLevels(splitLevel(s.Level))
+	logPtr.SetLineOptions(s.MaxLineBytes, s.Filter)
+	subLoggers[subLogger] = logPtr
 	return nil
 }
 
-// SetupSubLoggers configure all sub loggers with provided configuration values
-func SetupSubLoggers(s []SubLoggerConfig) error {
+// SetupSubLoggers configure all sub loggers with provided configuration values.
+// Entries naming a sub logger which does not exist are logged and skipped
+// rather than failing setup, their names are returned so callers can surface
+// the config typo
+func SetupSubLoggers(s []SubLoggerConfig) (unknown []string, err error) {
 	for x := range s {
-		output, err := getWriters(&s[x])
+		name := strings.ToUpper(s[x].Name)
+		if !subLoggerExists(name) {
+			Warnf(Global, "Sub logger %q in logging config does not exist, skipping\n", s[x].Name)
+			unknown = append(unknown, s[x].Name)
+			continue
+		}
+		var output io.Writer
+		output, err = getWriters(&s[x])
 		if err != nil {
-			return err
+			return unknown, err
 		}
-		err = configureSubLogger(strings.ToUpper(s[x].Name), s[x].Level, output)
+		err = configureSubLogger(name, &s[x], output)
 		if err != nil {
-			return err
+			return unknown, err
 		}
 	}
-	return nil
+	return unknown, nil
+}
+
+func subLoggerExists(name string) bool {
+	RWM.RLock()
+	defer RWM.RUnlock()
+	_, found := subLoggers[name]
+	return found
 }
 
 // SetupGlobalLogger setup the global loggers with the default global config values
@@ -105,19 +128,24 @@ func SetupGlobalLogger() error {
 
 	if FileLoggingConfiguredCorrectly {
 		GlobalLogFile = &Rotate{
-			FileName: GlobalLogConfig.LoggerFileConfig.FileName,
-			MaxSize:  GlobalLogConfig.LoggerFileConfig.MaxSize,
-			Rotate:   GlobalLogConfig.LoggerFileConfig.Rotate,
+			FileName:      GlobalLogConfig.LoggerFileConfig.FileName,
+			MaxSize:       GlobalLogConfig.LoggerFileConfig.MaxSize,
+			Rotate:        GlobalLogConfig.LoggerFileConfig.Rotate,
+			BufferSize:    GlobalLogConfig.LoggerFileConfig.BufferSize,
+			FlushInterval: GlobalLogConfig.LoggerFileConfig.FlushInterval,
 		}
 	}
 
-	for x := range SubLoggers {
-		SubLoggers[x].SetLevels(splitLevel(GlobalLogConfig.Level))
+	for x := range subLoggers {
+		subLoggers[x].SetLevels(splitLevel(GlobalLogConfig.Level))
+		// The global line length is the logger default set from
+		// AdvancedSettings, so no per sub logger override is set here
+		subLoggers[x].SetLineOptions(0, GlobalLogConfig.Filter)
 		writers, err := getWriters(&GlobalLogConfig.SubLoggerConfig)
 		if err != nil {
 			return err
 		}
-		SubLoggers[x].SetOutput(writers)
+		subLoggers[x].SetOutput(writers)
 	}
 	logger = newLogger(GlobalLogConfig)
 	return nil
@@ -140,6 +168,8 @@ func splitLevel(level string) (l Levels) {
 	return
 }
 
+// registerNewSubLogger registers a sub logger under its upper case name,
+// nil is returned if the name is already registered
 func registerNewSubLogger(subLogger string) *SubLogger {
 	temp := &SubLogger{
 		name:   strings.ToUpper(subLogger),
@@ -147,8 +177,11 @@ func registerNewSubLogger(subLogger string) *SubLogger {
 		levels: splitLevel("INFO|WARN|DEBUG|ERROR"),
 	}
 	RWM.Lock()
-	SubLoggers[subLogger] = temp
-	RWM.Unlock()
+	defer RWM.Unlock()
+	if _, ok := subLoggers[temp.name]; ok {
+		return nil
+	}
+	subLoggers[temp.name] = temp
 	return temp
 }
 
@@ -183,3 +216,91 @@ func init() {
 	Fill = registerNewSubLogger("FILL")
 	Currency = registerNewSubLogger("CURRENCY")
 }
+
+// ValidateConfig checks that the supplied logging config can be applied
+// without leaving the logger in a partially configured state. Entries for
+// unknown sub loggers are not validated as they are skipped on setup
+func ValidateConfig(c *Config) error {
+	if c == nil {
+		return errLoggingConfigIsNil
+	}
+	if c.Enabled == nil {
+		return fmt.Errorf("enabled %w", errRequiredFieldUnset)
+	}
+	if c.AdvancedSettings.ShowLogSystemName == nil {
+		return fmt.Errorf("showLogSystemName %w", errRequiredFieldUnset)
+	}
+
+	if c.AdvancedSettings.MaxLineBytes < 0 {
+		return fmt.Errorf("%w: %d", errInvalidMaxLineBytes, c.AdvancedSettings.MaxLineBytes)
+	}
+	if c.MaxLineBytes != 0 {
+		return errGlobalMaxLineBytes
+	}
+
+	usesFile, err := validateSubLoggerConfig(&c.SubLoggerConfig)
+	if err != nil {
+		return err
+	}
+	for x := range c.SubLoggers {
+		// Unknown sub loggers are skipped by SetupSubLoggers with a warning
+		if !subLoggerExists(strings.ToUpper(c.SubLoggers[x].Name)) {
+			continue
+		}
+		var subUsesFile bool
+		subUsesFile, err = validateSubLoggerConfig(&c.SubLoggers[x])
+		if err != nil {
+			return fmt.Errorf("sub logger %s: %w", c.SubLoggers[x].Name, err)
+		}
+		usesFile = usesFile || subUsesFile
+	}
+
+	if usesFile {
+		if c.LoggerFileConfig == nil || c.LoggerFileConfig.FileName == "" {
+			return fmt.Errorf("file output: %w", errFileNameIsEmpty)
+		}
+		if c.LoggerFileConfig.Rotate == nil {
+			return fmt.Errorf("file rotate %w", errRequiredFieldUnset)
+		}
+	}
+	return nil
+}
+
+// validateSubLoggerConfig checks the level and output values of a sub logger
+// config and returns whether it writes to the log file
+func validateSubLoggerConfig(s *SubLoggerConfig) (usesFile bool, err error) {
+	if err = validateLevel(s.Level); err != nil {
+		return false, err
+	}
+	if s.MaxLineBytes < 0 {
+		return false, fmt.Errorf("%w: %d", errInvalidMaxLineBytes, s.MaxLineBytes)
+	}
+	outputs := strings.Split(s.Output, "|")
+	for x := range outputs {
+		switch strings.ToLower(outputs[x]) {
+		case "stdout", "console", "stderr":
+		case "file":
+			usesFile = true
+		default:
+			return false, fmt.Errorf("%w: %s", errUnhandledOutputWriter, outputs[x])
+		}
+	}
+	return usesFile, nil
+}
+
+// validateLevel checks that every level in a pipe separated level string is
+// known, an empty string or LevelNone disables all levels
+func validateLevel(level string) error {
+	if level == "" || level == LevelNone {
+		return nil
+	}
+	levels := strings.Split(level, "|")
+	for x := range levels {
+		switch levels[x] {
+		case "DEBUG", "INFO", "WARN", "ERROR":
+		default:
+			return fmt.Errorf("%w: %s", errInvalidLogLevel, levels[x])
+		}
+	}
+	return nil
+}
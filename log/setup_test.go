@@ -0,0 +1,153 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+	if err := ValidateConfig(nil); !errors.Is(err, errLoggingConfigIsNil) {
+		t.Fatalf("received: %v but expected: %v", err, errLoggingConfigIsNil)
+	}
+
+	cfg := GenDefaultSettings()
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Level = "INFO|VERBOSE"
+	if err := ValidateConfig(cfg); !errors.Is(err, errInvalidLogLevel) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidLogLevel)
+	}
+
+	cfg = GenDefaultSettings()
+	cfg.SubLoggers = []SubLoggerConfig{{Name: "sync", Level: "DEBUG", Output: "printer"}}
+	if err := ValidateConfig(cfg); !errors.Is(err, errUnhandledOutputWriter) {
+		t.Fatalf("received: %v but expected: %v", err, errUnhandledOutputWriter)
+	}
+
+	cfg = GenDefaultSettings()
+	cfg.Output = "console|file"
+	cfg.LoggerFileConfig.FileName = ""
+	if err := ValidateConfig(cfg); !errors.Is(err, errFileNameIsEmpty) {
+		t.Fatalf("received: %v but expected: %v", err, errFileNameIsEmpty)
+	}
+
+	cfg = GenDefaultSettings()
+	cfg.Enabled = nil
+	if err := ValidateConfig(cfg); !errors.Is(err, errRequiredFieldUnset) {
+		t.Fatalf("received: %v but expected: %v", err, errRequiredFieldUnset)
+	}
+
+	cfg = GenDefaultSettings()
+	cfg.AdvancedSettings.MaxLineBytes = -1
+	if err := ValidateConfig(cfg); !errors.Is(err, errInvalidMaxLineBytes) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidMaxLineBytes)
+	}
+
+	cfg = GenDefaultSettings()
+	cfg.MaxLineBytes = 100
+	if err := ValidateConfig(cfg); !errors.Is(err, errGlobalMaxLineBytes) {
+		t.Fatalf("received: %v but expected: %v", err, errGlobalMaxLineBytes)
+	}
+}
+
+func TestSetupSubLoggersLevelChange(t *testing.T) {
+	t.Parallel()
+	sl := getTestSubLogger(t, "RELOADTEST")
+
+	_, err := SetupSubLoggers([]SubLoggerConfig{{Name: "reloadtest", Level: "ERROR", Output: "stdout"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels := sl.GetLevels(); levels.Debug || !levels.Error {
+		t.Fatalf("unexpected levels %+v", levels)
+	}
+
+	_, err = SetupSubLoggers([]SubLoggerConfig{{Name: "reloadtest", Level: "DEBUG|ERROR", Output: "stdout"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels := sl.GetLevels(); !levels.Debug || !levels.Error {
+		t.Fatalf("unexpected levels %+v", levels)
+	}
+}
+
+func TestSetupSubLoggersUnknown(t *testing.T) {
+	t.Parallel()
+	sl := getTestSubLogger(t, "UNKNOWNTEST")
+
+	unknown, err := SetupSubLoggers([]SubLoggerConfig{
+		{Name: "unknowntset", Level: "DEBUG", Output: "stdout"},
+		{Name: "unknowntest", Level: "WARN", Output: "stdout"},
+		{Name: "bogus", Level: "DEBUG", Output: "printer"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unknown) != 2 || unknown[0] != "unknowntset" || unknown[1] != "bogus" {
+		t.Fatalf("received: %v but expected: %v", unknown, []string{"unknowntset", "bogus"})
+	}
+	if levels := sl.GetLevels(); !levels.Warn || levels.Debug {
+		t.Fatalf("unexpected levels %+v", levels)
+	}
+
+	cfg := GenDefaultSettings()
+	cfg.SubLoggers = []SubLoggerConfig{{Name: "bogus", Level: "VERBOSE", Output: "printer"}}
+	if err = ValidateConfig(cfg); err != nil {
+		t.Fatalf("received: %v but expected: %v", err, nil)
+	}
+}
+
+func TestSetupSubLoggersLineOptions(t *testing.T) {
+	t.Parallel()
+	sl := getTestSubLogger(t, "FRAMETEST")
+
+	var buf bytes.Buffer
+	_, err := SetupSubLoggers([]SubLoggerConfig{{
+		Name:         "frametest",
+		Level:        "DEBUG",
+		Output:       "stdout",
+		MaxLineBytes: 20,
+		Filter:       "BTC-USD|ETH-USD",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl.SetOutput(&buf)
+
+	fields, ok := sl.getFields()
+	if !ok {
+		t.Fatal("expected log fields")
+	}
+	frames := []string{
+		`{"channel":"ticker","pair":"BTC-USD"}`,
+		`{"channel":"ticker","pair":"LTC-USD"}`,
+		`{"pair":"ETH-USD"}`,
+	}
+	for x := range frames {
+		err = fields.logger.newLogEvent(frames[x], "[DEBUG]", fields.name, fields.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("received: %d lines but expected: 2, %q", len(lines), buf.String())
+	}
+	if !strings.HasSuffix(lines[0], `{"channel":"ticker",...(truncated 17 bytes)`) {
+		t.Errorf("unexpected truncation %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], `{"pair":"ETH-USD"}`) {
+		t.Errorf("unexpected line %q", lines[1])
+	}
+
+	cfg := GenDefaultSettings()
+	cfg.SubLoggers = []SubLoggerConfig{{Name: "frametest", Level: "DEBUG", Output: "stdout", MaxLineBytes: -1}}
+	if err = ValidateConfig(cfg); !errors.Is(err, errInvalidMaxLineBytes) {
+		t.Fatalf("received: %v but expected: %v", err, errInvalidMaxLineBytes)
+	}
+}
@@ -8,7 +8,9 @@ import (
 
 // Global vars related to the logger package
 var (
-	SubLoggers = map[string]*SubLogger{}
+	// subLoggers holds every registered sub logger keyed by upper case name,
+	// guarded by RWM. Use GetSubLogger and ForEachSubLogger for access
+	subLoggers = map[string]*SubLogger{}
 
 	Global           *SubLogger
 	BackTester       *SubLogger
@@ -42,10 +44,12 @@ var (
 // SubLogger defines a sub logger can be used externally for packages wanted to
 // leverage GCT library logger features.
 type SubLogger struct {
-	name   string
-	levels Levels
-	output io.Writer
-	mtx    sync.RWMutex
+	name         string
+	levels       Levels
+	output       io.Writer
+	maxLineBytes int
+	filter       [][]byte
+	mtx          sync.RWMutex
 }
 
 // logFields is used to store data in a non-global and thread-safe manner
@@ -65,14 +69,36 @@ func NewSubLogger(name string) (*SubLogger, error) {
 	if name == "" {
 		return nil, errEmptyLoggerName
 	}
-	name = strings.ToUpper(name)
-	RWM.RLock()
-	if _, ok := SubLoggers[name]; ok {
-		RWM.RUnlock()
+	sl := registerNewSubLogger(name)
+	if sl == nil {
 		return nil, errSubLoggerAlreadyregistered
 	}
+	return sl, nil
+}
+
+// GetSubLogger returns the registered sub logger with the supplied case
+// insensitive name
+func GetSubLogger(name string) (*SubLogger, bool) {
+	RWM.RLock()
+	defer RWM.RUnlock()
+	sl, ok := subLoggers[strings.ToUpper(name)]
+	return sl, ok
+}
+
+// ForEachSubLogger calls fn with each registered sub logger. fn is called
+// without the logger lock held, so it may log and register sub loggers
+func ForEachSubLogger(fn func(name string, sl *SubLogger)) {
+	RWM.RLock()
+	names := make([]string, 0, len(subLoggers))
+	loggers := make([]*SubLogger, 0, len(subLoggers))
+	for name, sl := range subLoggers {
+		names = append(names, name)
+		loggers = append(loggers, sl)
+	}
 	RWM.RUnlock()
-	return registerNewSubLogger(name), nil
+	for x := range names {
+		fn(names[x], loggers[x])
+	}
 }
 
 // SetOutput overrides the default output with a new writer
@@ -89,6 +115,22 @@ func (sl *SubLogger) SetLevels(newLevels Levels) {
 	sl.mtx.Unlock()
 }
 
+// SetLineOptions sets the maximum message length, overriding the global
+// setting when above zero, and the pipe separated message filter of the sub
+// logger. An empty filter writes every message
+func (sl *SubLogger) SetLineOptions(maxLineBytes int, filter string) {
+	var filters [][]byte
+	if filter != "" {
+		for _, f := range strings.Split(filter, "|") {
+			filters = append(filters, []byte(f))
+		}
+	}
+	sl.mtx.Lock()
+	sl.maxLineBytes = maxLineBytes
+	sl.filter = filters
+	sl.mtx.Unlock()
+}
+
 // GetLevels returns current functional log levels
 func (sl *SubLogger) GetLevels() Levels {
 	sl.mtx.RLock()
@@ -96,7 +138,11 @@ func (sl *SubLogger) GetLevels() Levels {
 	return sl.levels
 }
 
-func (sl *SubLogger) getFields() *logFields {
+// getFields returns a copy of the sub logger's settings, ok is false when
+// the sub logger is nil, has every level disabled or logging is disabled.
+// Fields are returned by value so checking a disabled level does not
+// allocate
+func (sl *SubLogger) getFields() (fields logFields, ok bool) {
 	RWM.RLock()
 	defer RWM.RUnlock()
 
@@ -104,12 +150,16 @@ func (sl *SubLogger) getFields() *logFields {
 		(GlobalLogConfig != nil &&
 			GlobalLogConfig.Enabled != nil &&
 			!*GlobalLogConfig.Enabled) {
-		return nil
+		return logFields{}, false
 	}
 
 	sl.mtx.RLock()
 	defer sl.mtx.RUnlock()
-	return &logFields{
+	if !sl.levels.Info && !sl.levels.Debug && !sl.levels.Warn && !sl.levels.Error {
+		// Disabled sub loggers skip copying the logger settings
+		return logFields{}, false
+	}
+	fields = logFields{
 		info:   sl.levels.Info,
 		warn:   sl.levels.Warn,
 		debug:  sl.levels.Debug,
@@ -118,4 +168,9 @@ func (sl *SubLogger) getFields() *logFields {
 		output: sl.output,
 		logger: logger,
 	}
-}
\ No newline at end of file
+	if sl.maxLineBytes > 0 {
+		fields.logger.MaxLineBytes = sl.maxLineBytes
+	}
+	fields.logger.filter = sl.filter
+	return fields, true
+}
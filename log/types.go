@@ -3,6 +3,7 @@ package log
 import (
 	"io"
 	"sync"
+	"time"
 )
 
 const (
@@ -12,6 +13,9 @@ const (
 	DefaultMaxFileSize int64 = 100
 
 	defaultCapacityForSliceOfBytes = 100
+
+	// LevelNone is the level keyword which disables a sub logger entirely
+	LevelNone = "NONE"
 )
 
 var (
@@ -35,6 +39,12 @@ var (
 
 	// RWM read/write mutex for logger
 	RWM = &sync.RWMutex{}
+
+	levelReverts revertScheduler
+
+	// timeNow is the time source for log timestamps and rotated file names,
+	// replaced in tests to freeze time
+	timeNow = time.Now
 )
 
 // Config holds configuration settings loaded from bot config
@@ -51,6 +61,9 @@ type advancedSettings struct {
 	Spacer            string  `json:"spacer"`
 	TimeStampFormat   string  `json:"timeStampFormat"`
 	Headers           headers `json:"headers"`
+	// MaxLineBytes truncates the message portion of a log line beyond this
+	// many bytes, zero is unlimited
+	MaxLineBytes int `json:"maxLineBytes,omitempty"`
 }
 
 type headers struct {
@@ -62,15 +75,28 @@ type headers struct {
 
 // SubLoggerConfig holds sub logger configuration settings loaded from bot config
 type SubLoggerConfig struct {
-	Name   string `json:"name,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Level is a pipe separated list of enabled levels, LevelNone disables
+	// the sub logger entirely
 	Level  string `json:"level"`
 	Output string `json:"output"`
+	// MaxLineBytes overrides AdvancedSettings.MaxLineBytes for an entry in
+	// SubLoggers when above zero, it is rejected on the top level config
+	MaxLineBytes int `json:"maxLineBytes,omitempty"`
+	// Filter is a pipe separated list of case sensitive substrings, when set
+	// only messages containing one of them are written
+	Filter string `json:"filter,omitempty"`
 }
 
 type loggerFileConfig struct {
 	FileName string `json:"filename,omitempty"`
 	Rotate   *bool  `json:"rotate,omitempty"`
 	MaxSize  int64  `json:"maxsize,omitempty"`
+	// BufferSize in bytes enables buffered file writes, trading up to one
+	// FlushInterval of log latency (and of lost lines on a crash) for fewer
+	// write syscalls under bursty logging
+	BufferSize    int           `json:"bufferSize,omitempty"`
+	FlushInterval time.Duration `json:"flushInterval,omitempty"`
 }
 
 // Logger each instance of logger settings
@@ -79,6 +105,8 @@ type Logger struct {
 	Timestamp                                        string
 	InfoHeader, ErrorHeader, DebugHeader, WarnHeader string
 	Spacer                                           string
+	MaxLineBytes                                     int
+	filter                                           [][]byte
 }
 
 // Levels flags for each sub logger type
@@ -86,6 +114,17 @@ type Levels struct {
 	Info, Debug, Warn, Error bool
 }
 
+// revertScheduler restores sub logger levels after a timed level change
+type revertScheduler struct {
+	pending map[*SubLogger]*pendingRevert
+	mu      sync.Mutex
+}
+
+type pendingRevert struct {
+	levels Levels
+	timer  *time.Timer
+}
+
 type multiWriterHolder struct {
 	writers []io.Writer
 	mu      sync.RWMutex